@@ -0,0 +1,113 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database /* import "mig.ninja/mig/database" */
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Driver abstracts the parts of query construction that differ between
+// database engines, so the search query builders below don't have to
+// hard-code PostgreSQL-style `$N` placeholders. It intentionally does not
+// attempt to abstract PostgreSQL-specific predicates this package also
+// relies on (JSONB operators, ILIKE, to_tsvector full-text search,
+// pg_class row estimates): those remain Postgres-only until an equivalent
+// is written for each additional engine. Until that work lands, mysqlDriver
+// and sqliteDriver exist only so DriverForURL can recognize and reject
+// those schemes with a clear error instead of Open silently handing back a
+// connection every query will fail against; only postgresDriver's Open
+// succeeds, as Postgres is the only engine this package can actually run
+// its generated SQL on.
+type Driver interface {
+	// Name identifies the driver, e.g. "postgres", "mysql" or "sqlite".
+	Name() string
+	// Placeholder renders the nth (1-indexed) bound parameter in this
+	// driver's query syntax: "$3" for Postgres, "?" for MySQL and SQLite.
+	Placeholder(n int) string
+	// QuoteIdent quotes name as a safe identifier for this driver.
+	QuoteIdent(name string) string
+	// Open opens a connection pool for dsn using this driver.
+	Open(dsn string) (*sql.DB, error)
+}
+
+// DriverForURL picks a Driver based on rawurl's scheme. Only "postgres"/
+// "postgresql" is backed by a working Open today; "mysql" and
+// "sqlite"/"sqlite3" are recognized so config validation can give a clear
+// "not yet supported" error instead of an unrecognized-scheme one, but
+// their Open always fails -- see the Driver doc comment.
+func DriverForURL(rawurl string) (Driver, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL %q: %v", rawurl, err)
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return postgresDriver{}, nil
+	case "mysql":
+		return mysqlDriver{}, nil
+	case "sqlite", "sqlite3":
+		return sqliteDriver{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver scheme %q", u.Scheme)
+	}
+}
+
+// defaultDriver is used by the search query builders until DB grows its own
+// driver field; DB itself is declared in a file outside this snapshot.
+// SetDefaultDriver replaces it, but the generated SQL only runs on Postgres
+// (see Driver), so postgresDriver is the only driver searches work with.
+var defaultDriver Driver = postgresDriver{}
+
+// SetDefaultDriver changes the driver used by subsequent searches.
+func SetDefaultDriver(d Driver) {
+	defaultDriver = d
+}
+
+type postgresDriver struct{}
+
+func (postgresDriver) Name() string { return "postgres" }
+
+func (postgresDriver) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDriver) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgresDriver) Open(dsn string) (*sql.DB, error) { return sql.Open("postgres", dsn) }
+
+type mysqlDriver struct{}
+
+func (mysqlDriver) Name() string { return "mysql" }
+
+func (mysqlDriver) Placeholder(n int) string { return "?" }
+
+func (mysqlDriver) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (mysqlDriver) Open(dsn string) (*sql.DB, error) {
+	return nil, fmt.Errorf("mysql is not yet supported: the search query builders in this package " +
+		"rely on Postgres-only SQL (ILIKE, JSONB operators, to_tsvector full-text search) that " +
+		"hasn't been ported to MySQL syntax")
+}
+
+type sqliteDriver struct{}
+
+func (sqliteDriver) Name() string { return "sqlite" }
+
+func (sqliteDriver) Placeholder(n int) string { return "?" }
+
+func (sqliteDriver) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDriver) Open(dsn string) (*sql.DB, error) {
+	return nil, fmt.Errorf("sqlite is not yet supported: the search query builders in this package " +
+		"rely on Postgres-only SQL (ILIKE, JSONB operators, to_tsvector full-text search) that " +
+		"hasn't been ported to SQLite syntax")
+}
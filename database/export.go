@@ -0,0 +1,142 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database /* import "mig.ninja/mig/database" */
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExportFormat selects the on-wire encoding used by the export* functions.
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+// exportInvestigators streams every investigator matching p to w as CSV or
+// NDJSON, reusing SearchInvestigatorsStream's query builder. Unlike
+// SearchInvestigators, it never buffers the whole result set: only the few
+// rows in flight between the stream's scanner, its workers and the encoder
+// are held in memory. It flushes after every row, so an audit export of the
+// whole table doesn't have to be paged through Limit/Cursor.
+func (db *DB) exportInvestigators(p SearchParameters, auth Permissions, w io.Writer, format ExportFormat) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	invChan, errChan := db.SearchInvestigatorsStream(ctx, p, auth, 0)
+	enc, err := newRowEncoder(w, format, []string{"id", "name", "pgpfingerprint", "status", "createdat", "lastmodified"})
+	if err != nil {
+		return err
+	}
+	for inv := range invChan {
+		if err := enc.write(inv.ID, inv.Name, inv.PGPFingerprint, inv.Status, inv.CreatedAt, inv.LastModified); err != nil {
+			return err
+		}
+	}
+	return <-errChan
+}
+
+// exportActions streams every action matching p to w as CSV or NDJSON,
+// reusing SearchActionsStream's query builder. See exportInvestigators for
+// the streaming/memory behavior.
+func (db *DB) exportActions(p SearchParameters, auth Permissions, w io.Writer, format ExportFormat) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	actionChan, errChan := db.SearchActionsStream(ctx, p, auth, 0)
+	enc, err := newRowEncoder(w, format, []string{"id", "name", "target", "validfrom", "expireafter", "starttime", "finishtime", "lastupdatetime", "status"})
+	if err != nil {
+		return err
+	}
+	for a := range actionChan {
+		if err := enc.write(a.ID, a.Name, a.Target, a.ValidFrom, a.ExpireAfter, a.StartTime, a.FinishTime, a.LastUpdateTime, a.Status); err != nil {
+			return err
+		}
+	}
+	return <-errChan
+}
+
+// exportCommands streams every command matching p to w as CSV or NDJSON,
+// reusing SearchCommandsStream's query builder. See exportInvestigators for
+// the streaming/memory behavior.
+func (db *DB) exportCommands(p SearchParameters, doFoundAnything bool, auth Permissions, w io.Writer, format ExportFormat) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmdChan, errChan := db.SearchCommandsStream(ctx, p, doFoundAnything, auth, 0)
+	enc, err := newRowEncoder(w, format, []string{"id", "status", "starttime", "finishtime", "actionid", "actionname", "agentid", "agentname"})
+	if err != nil {
+		return err
+	}
+	for cmd := range cmdChan {
+		if err := enc.write(cmd.ID, cmd.Status, cmd.StartTime, cmd.FinishTime, cmd.Action.ID, cmd.Action.Name, cmd.Agent.ID, cmd.Agent.Name); err != nil {
+			return err
+		}
+	}
+	return <-errChan
+}
+
+// rowEncoder writes one exported row at a time in the chosen ExportFormat,
+// flushing to the underlying writer after every row.
+type rowEncoder struct {
+	format  ExportFormat
+	columns []string
+	csvw    *csv.Writer
+	jsonw   *bufio.Writer
+}
+
+func newRowEncoder(w io.Writer, format ExportFormat, columns []string) (*rowEncoder, error) {
+	enc := &rowEncoder{format: format, columns: columns}
+	switch format {
+	case ExportCSV:
+		enc.csvw = csv.NewWriter(w)
+		if err := enc.csvw.Write(columns); err != nil {
+			return nil, fmt.Errorf("failed to write CSV header: '%v'", err)
+		}
+		enc.csvw.Flush()
+		return enc, enc.csvw.Error()
+	case ExportNDJSON:
+		enc.jsonw = bufio.NewWriter(w)
+		return enc, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func (e *rowEncoder) write(values ...interface{}) error {
+	switch e.format {
+	case ExportCSV:
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = fmt.Sprintf("%v", v)
+		}
+		if err := e.csvw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: '%v'", err)
+		}
+		e.csvw.Flush()
+		return e.csvw.Error()
+	case ExportNDJSON:
+		row := make(map[string]interface{}, len(values))
+		for i, v := range values {
+			row[e.columns[i]] = v
+		}
+		buf, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to marshal NDJSON row: '%v'", err)
+		}
+		if _, err := e.jsonw.Write(buf); err != nil {
+			return fmt.Errorf("failed to write NDJSON row: '%v'", err)
+		}
+		if err := e.jsonw.WriteByte('\n'); err != nil {
+			return err
+		}
+		return e.jsonw.Flush()
+	default:
+		return fmt.Errorf("unsupported export format %q", e.format)
+	}
+}
@@ -0,0 +1,54 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRowEncoderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := newRowEncoder(&buf, ExportCSV, []string{"id", "name"})
+	if err != nil {
+		t.Fatalf("newRowEncoder: unexpected error: %v", err)
+	}
+	if err := enc.write(float64(1), "alice"); err != nil {
+		t.Fatalf("write: unexpected error: %v", err)
+	}
+	if err := enc.write(float64(2), "bob"); err != nil {
+		t.Fatalf("write: unexpected error: %v", err)
+	}
+	want := "id,name\n1,alice\n2,bob\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV output = %q, want %q", got, want)
+	}
+}
+
+func TestRowEncoderNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := newRowEncoder(&buf, ExportNDJSON, []string{"id", "name"})
+	if err != nil {
+		t.Fatalf("newRowEncoder: unexpected error: %v", err)
+	}
+	if err := enc.write(float64(1), "alice"); err != nil {
+		t.Fatalf("write: unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("NDJSON output has %d lines, want 1: %q", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"id":1`) || !strings.Contains(lines[0], `"name":"alice"`) {
+		t.Errorf("NDJSON row = %q, missing expected fields", lines[0])
+	}
+}
+
+func TestNewRowEncoderUnsupportedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := newRowEncoder(&buf, ExportFormat("xml"), []string{"id"}); err == nil {
+		t.Error("newRowEncoder: expected an error for an unsupported format, got none")
+	}
+}
@@ -0,0 +1,184 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package jsonquery implements a small predicate DSL that compiles to a
+// Postgres 12+ jsonpath filter expression, so SearchParameters.ResultQuery
+// and OperationQuery can express conditions like:
+//
+//	module=file AND path~"/etc/.*" AND foundanything=true
+//
+// without callers having to hand-write jsonpath against commands.results or
+// actions.operations.
+package jsonquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Op is a comparison operator supported by the DSL.
+type Op string
+
+const (
+	OpEq    Op = "="
+	OpMatch Op = "~"
+	OpGT    Op = ">"
+	OpLT    Op = "<"
+	OpGE    Op = ">="
+	OpLE    Op = "<="
+)
+
+// Predicate is a single `field OP value` clause.
+type Predicate struct {
+	Field string
+	Op    Op
+	Value interface{} // string, float64, or bool
+}
+
+// Query is a set of Predicates ANDed together.
+type Query struct {
+	Predicates []Predicate
+}
+
+// Parse compiles a DSL expression into a Query. Clauses are separated by
+// the literal (case-insensitive) keyword AND; only conjunction is
+// supported, which covers the "module=X AND path~Y" style filters the API
+// needs.
+func Parse(expr string) (q Query, err error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return q, nil
+	}
+	clauses := splitAND(expr)
+	for _, clause := range clauses {
+		p, err := parsePredicate(strings.TrimSpace(clause))
+		if err != nil {
+			return q, err
+		}
+		q.Predicates = append(q.Predicates, p)
+	}
+	return q, nil
+}
+
+// splitAND splits expr on the case-insensitive word "AND" outside of quoted
+// strings.
+func splitAND(expr string) []string {
+	var clauses []string
+	var cur strings.Builder
+	inQuotes := false
+	runes := []rune(expr)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if c == '"' {
+			inQuotes = !inQuotes
+			cur.WriteRune(c)
+			continue
+		}
+		if !inQuotes && (c == 'A' || c == 'a') && i+3 <= len(runes) &&
+			strings.EqualFold(string(runes[i:i+3]), "AND") &&
+			(i == 0 || runes[i-1] == ' ') && (i+3 == len(runes) || runes[i+3] == ' ') {
+			clauses = append(clauses, cur.String())
+			cur.Reset()
+			i += 2
+			continue
+		}
+		cur.WriteRune(c)
+	}
+	clauses = append(clauses, cur.String())
+	return clauses
+}
+
+// parsePredicate parses a single `field OP value` clause. Operators are
+// checked longest-first so `>=`/`<=` aren't mistaken for `>`/`<`.
+func parsePredicate(clause string) (p Predicate, err error) {
+	ops := []Op{OpGE, OpLE, OpMatch, OpEq, OpGT, OpLT}
+	var op Op
+	idx := -1
+	for _, candidate := range ops {
+		if i := strings.Index(clause, string(candidate)); i >= 0 {
+			if idx == -1 || i < idx {
+				idx = i
+				op = candidate
+			}
+		}
+	}
+	if idx == -1 {
+		return p, fmt.Errorf("jsonquery: no operator found in clause %q", clause)
+	}
+	field := strings.TrimSpace(clause[:idx])
+	rawValue := strings.TrimSpace(clause[idx+len(op):])
+	if field == "" || rawValue == "" {
+		return p, fmt.Errorf("jsonquery: malformed clause %q", clause)
+	}
+	return Predicate{Field: field, Op: op, Value: parseValue(rawValue)}, nil
+}
+
+// parseValue infers the type of a raw DSL value: a quoted string stays a
+// string, true/false become bool, anything else that parses as a float
+// becomes numeric, otherwise it's treated as a bare string.
+func parseValue(raw string) interface{} {
+	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		return raw[1 : len(raw)-1]
+	}
+	switch strings.ToLower(raw) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	return raw
+}
+
+// Jsonpath renders the query as a Postgres jsonpath filter expression
+// suitable for `column @? '$[*] ? (...)'`, e.g.
+// `$[*] ? (@.module == "file" && @.path like_regex "/etc/.*")`.
+func (q Query) Jsonpath() string {
+	if len(q.Predicates) == 0 {
+		return "$[*]"
+	}
+	parts := make([]string, len(q.Predicates))
+	for i, p := range q.Predicates {
+		parts[i] = p.jsonpath()
+	}
+	return fmt.Sprintf("$[*] ? (%s)", strings.Join(parts, " && "))
+}
+
+func (p Predicate) jsonpath() string {
+	switch p.Op {
+	case OpMatch:
+		return fmt.Sprintf(`@.%s like_regex %s`, p.Field, quoteJSONPathString(p.Value))
+	case OpEq:
+		return fmt.Sprintf(`@.%s == %s`, p.Field, jsonpathLiteral(p.Value))
+	default:
+		return fmt.Sprintf(`@.%s %s %s`, p.Field, p.Op, jsonpathLiteral(p.Value))
+	}
+}
+
+// jsonpathLiteral renders a Go value as a jsonpath literal.
+func jsonpathLiteral(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return quoteJSONPathString(val)
+	case bool:
+		if val {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	default:
+		return quoteJSONPathString(fmt.Sprintf("%v", val))
+	}
+}
+
+func quoteJSONPathString(v interface{}) string {
+	s, _ := v.(string)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return fmt.Sprintf(`"%s"`, s)
+}
@@ -0,0 +1,121 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package jsonquery
+
+import "testing"
+
+func TestParseSingleClause(t *testing.T) {
+	q, err := Parse(`module=file`)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if len(q.Predicates) != 1 {
+		t.Fatalf("Parse: got %d predicates, want 1", len(q.Predicates))
+	}
+	want := Predicate{Field: "module", Op: OpEq, Value: "file"}
+	if q.Predicates[0] != want {
+		t.Errorf("Parse: predicate = %+v, want %+v", q.Predicates[0], want)
+	}
+}
+
+func TestParseMultipleClausesAndOperators(t *testing.T) {
+	q, err := Parse(`module=file AND path~"/etc/.*" AND foundanything=true AND count>=3`)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	want := []Predicate{
+		{Field: "module", Op: OpEq, Value: "file"},
+		{Field: "path", Op: OpMatch, Value: "/etc/.*"},
+		{Field: "foundanything", Op: OpEq, Value: true},
+		{Field: "count", Op: OpGE, Value: float64(3)},
+	}
+	if len(q.Predicates) != len(want) {
+		t.Fatalf("Parse: got %d predicates, want %d: %+v", len(q.Predicates), len(want), q.Predicates)
+	}
+	for i := range want {
+		if q.Predicates[i] != want[i] {
+			t.Errorf("Parse: predicate %d = %+v, want %+v", i, q.Predicates[i], want[i])
+		}
+	}
+}
+
+func TestParseEmptyExpression(t *testing.T) {
+	q, err := Parse("  ")
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if len(q.Predicates) != 0 {
+		t.Errorf("Parse: expected no predicates for an empty expression, got %+v", q.Predicates)
+	}
+}
+
+func TestParseANDInsideQuotesIsNotASeparator(t *testing.T) {
+	q, err := Parse(`path~"foo AND bar"`)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if len(q.Predicates) != 1 {
+		t.Fatalf("Parse: got %d predicates, want 1 -- AND inside quotes must not split the clause", len(q.Predicates))
+	}
+	if want := "foo AND bar"; q.Predicates[0].Value != want {
+		t.Errorf("Parse: value = %q, want %q", q.Predicates[0].Value, want)
+	}
+}
+
+func TestParseMalformedClauseErrors(t *testing.T) {
+	if _, err := Parse("no-operator-here"); err == nil {
+		t.Error("Parse: expected an error for a clause with no operator, got none")
+	}
+	if _, err := Parse("=value"); err == nil {
+		t.Error("Parse: expected an error for a clause with an empty field, got none")
+	}
+	if _, err := Parse("field="); err == nil {
+		t.Error("Parse: expected an error for a clause with an empty value, got none")
+	}
+}
+
+func TestParsePrefersLongOperatorsOverShortOnes(t *testing.T) {
+	q, err := Parse("count>=3")
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if q.Predicates[0].Op != OpGE {
+		t.Errorf("Parse: op = %q, want %q -- >= must not be mistaken for >", q.Predicates[0].Op, OpGE)
+	}
+	q, err = Parse("count<=3")
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if q.Predicates[0].Op != OpLE {
+		t.Errorf("Parse: op = %q, want %q -- <= must not be mistaken for <", q.Predicates[0].Op, OpLE)
+	}
+}
+
+func TestQueryJsonpath(t *testing.T) {
+	q, err := Parse(`module=file AND path~"/etc/.*"`)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	want := `$[*] ? (@.module == "file" && @.path like_regex "/etc/.*")`
+	if got := q.Jsonpath(); got != want {
+		t.Errorf("Jsonpath() = %q, want %q", got, want)
+	}
+}
+
+func TestQueryJsonpathEmpty(t *testing.T) {
+	var q Query
+	if got, want := q.Jsonpath(), "$[*]"; got != want {
+		t.Errorf("Jsonpath() = %q, want %q", got, want)
+	}
+}
+
+func TestQueryJsonpathEscapesQuotesAndBackslashes(t *testing.T) {
+	q := Query{Predicates: []Predicate{{Field: "path", Op: OpMatch, Value: `C:\temp\"quoted"`}}}
+	got := q.Jsonpath()
+	want := `$[*] ? (@.path like_regex "C:\\temp\\\"quoted\"")`
+	if got != want {
+		t.Errorf("Jsonpath() = %q, want %q", got, want)
+	}
+}
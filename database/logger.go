@@ -0,0 +1,75 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database /* import "mig.ninja/mig/database" */
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Logger receives a trace of every query the search query builders in this
+// package send to the database, so operators get visibility into what the
+// dynamic join/where/GROUP BY construction actually produced, how long it
+// took, and how many rows it touched -- none of which is otherwise visible
+// in production.
+type Logger interface {
+	// Trace reports one completed query. begin is when the query started;
+	// rowsAffected is -1 when the row count isn't known at trace time (as
+	// for the streaming Search* queries, whose rows are still being
+	// consumed when the query itself completes); err is the error the
+	// query returned, if any.
+	Trace(ctx context.Context, begin time.Time, sql string, args []interface{}, rowsAffected int64, err error)
+}
+
+// defaultSlowThreshold is the elapsed time above which StdLogger promotes a
+// query from Info to Warn.
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// defaultLogger is used by the search query builders until DB grows its own
+// Logger field; DB itself is declared in a file outside this snapshot. Call
+// SetLogger during startup to route traces elsewhere (e.g. into MIG's own
+// mozlog output).
+var defaultLogger Logger = NewStdLogger(log.Default())
+
+// SetLogger changes the logger used by subsequent queries.
+func SetLogger(l Logger) {
+	defaultLogger = l
+}
+
+// StdLogger is the default Logger, modeled on GORM's: every query is logged
+// at Info, queries slower than SlowThreshold are promoted to Warn and
+// include their full argument vector, and errors always log at Error.
+type StdLogger struct {
+	out *log.Logger
+
+	// SlowThreshold is the elapsed time above which a query is logged as
+	// slow. The zero value uses defaultSlowThreshold.
+	SlowThreshold time.Duration
+}
+
+// NewStdLogger wraps out as a StdLogger with the default SlowThreshold.
+func NewStdLogger(out *log.Logger) *StdLogger {
+	return &StdLogger{out: out, SlowThreshold: defaultSlowThreshold}
+}
+
+func (l *StdLogger) Trace(ctx context.Context, begin time.Time, sql string, args []interface{}, rowsAffected int64, err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil:
+		l.out.Printf("[ERROR] %s | rows=%d | %s | args=%v | %v", elapsed, rowsAffected, sql, args, err)
+	case elapsed > l.slowThreshold():
+		l.out.Printf("[WARN] slow query | %s | rows=%d | %s | args=%v", elapsed, rowsAffected, sql, args)
+	default:
+		l.out.Printf("[INFO] %s | rows=%d | %s", elapsed, rowsAffected, sql)
+	}
+}
+
+func (l *StdLogger) slowThreshold() time.Duration {
+	if l.SlowThreshold == 0 {
+		return defaultSlowThreshold
+	}
+	return l.SlowThreshold
+}
@@ -0,0 +1,217 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database /* import "mig.ninja/mig/database" */
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// retentionLockID is the pg_try_advisory_lock key RetentionWorker
+// coordinates on, so only one scheduler instance runs GC against a given
+// database at a time. It's an arbitrary constant, chosen not to collide
+// with advisory locks taken elsewhere.
+const retentionLockID int64 = 0x4d494752 // "MIGR"
+
+// RetentionConfig controls how long commands, actions, and agent heartbeats
+// are kept before RetentionWorker prunes them, and how often it runs.
+// Pruned actions are moved into actions_archive, whose rows are not pruned.
+// The retention windows are in days; a window <= 0 disables pruning for
+// that table.
+type RetentionConfig struct {
+	RetainCommands   int
+	RetainActions    int
+	RetainHeartbeats int
+	Interval         time.Duration
+}
+
+// RetentionStats reports the outcome of a single RetentionWorker cycle, so
+// callers can export it as metrics.
+type RetentionStats struct {
+	CommandsDeleted   int64
+	ActionsArchived   int64
+	HeartbeatsDeleted int64
+	Duration          time.Duration
+}
+
+// RetentionWorker periodically deletes expired commands, archives completed
+// actions into actions_archive, and drops stale agent heartbeat rows.
+// Because multiple scheduler instances may run against the same database,
+// each cycle only proceeds if it wins the retention advisory lock via
+// db.TryAcquireLock, so at most one worker performs GC at a time.
+type RetentionWorker struct {
+	db   *DB
+	conf RetentionConfig
+
+	// OnCycle, if set, is called with the stats of every completed cycle.
+	// Cycles that don't win the advisory lock return before OnCycle is
+	// invoked.
+	OnCycle func(RetentionStats)
+}
+
+// NewRetentionWorker creates a RetentionWorker for db using conf. Call Run
+// to start its ticker loop.
+func NewRetentionWorker(db *DB, conf RetentionConfig) *RetentionWorker {
+	return &RetentionWorker{db: db, conf: conf}
+}
+
+// Run blocks, running one GC cycle every conf.Interval until ctx is
+// cancelled.
+func (w *RetentionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.runCycle(ctx)
+		}
+	}
+}
+
+// runCycle attempts to take the retention advisory lock and, if successful,
+// runs one pass of deletion/archival/vacuum over commands, actions and
+// agents, logging and reporting the resulting RetentionStats.
+func (w *RetentionWorker) runCycle(ctx context.Context) {
+	conn, acquired, err := w.db.TryAcquireLock(ctx, retentionLockID)
+	if err != nil {
+		log.Printf("retention: failed to acquire advisory lock: %v", err)
+		return
+	}
+	if !acquired {
+		// another scheduler instance is already running GC
+		return
+	}
+	defer func() {
+		if err := w.db.ReleaseLock(ctx, conn, retentionLockID); err != nil {
+			log.Printf("retention: failed to release advisory lock: %v", err)
+		}
+	}()
+
+	start := time.Now()
+	var stats RetentionStats
+
+	if w.conf.RetainCommands > 0 {
+		stats.CommandsDeleted, err = w.deleteExpiredCommands(ctx, w.conf.RetainCommands)
+		if err != nil {
+			log.Printf("retention: %v", err)
+		}
+	}
+	if w.conf.RetainActions > 0 {
+		stats.ActionsArchived, err = w.archiveExpiredActions(ctx, w.conf.RetainActions)
+		if err != nil {
+			log.Printf("retention: %v", err)
+		}
+	}
+	if w.conf.RetainHeartbeats > 0 {
+		stats.HeartbeatsDeleted, err = w.deleteStaleHeartbeats(ctx, w.conf.RetainHeartbeats)
+		if err != nil {
+			log.Printf("retention: %v", err)
+		}
+	}
+	if _, err := w.db.c.ExecContext(ctx, `VACUUM ANALYZE commands, actions, agents`); err != nil {
+		log.Printf("retention: vacuum analyze failed: %v", err)
+	}
+
+	stats.Duration = time.Since(start)
+	log.Printf("retention: cycle complete in %s: commands_deleted=%d actions_archived=%d heartbeats_deleted=%d",
+		stats.Duration, stats.CommandsDeleted, stats.ActionsArchived, stats.HeartbeatsDeleted)
+	if w.OnCycle != nil {
+		w.OnCycle(stats)
+	}
+}
+
+// deleteExpiredCommands removes commands whose starttime is older than
+// retainDays and returns the number of rows deleted.
+func (w *RetentionWorker) deleteExpiredCommands(ctx context.Context, retainDays int) (int64, error) {
+	res, err := w.db.c.ExecContext(ctx,
+		`DELETE FROM commands WHERE starttime < NOW() - ($1 || ' days')::interval`, retainDays)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired commands: '%v'", err)
+	}
+	return res.RowsAffected()
+}
+
+// archiveExpiredActions copies completed or failed actions whose
+// expireafter is older than retainDays into actions_archive, then deletes
+// them from actions, and returns the number of rows archived.
+func (w *RetentionWorker) archiveExpiredActions(ctx context.Context, retainDays int) (int64, error) {
+	res, err := w.db.c.ExecContext(ctx, `
+		INSERT INTO actions_archive (id, name, target, validfrom, expireafter, lastupdatetime, status)
+		SELECT id, name, target, validfrom, expireafter, lastupdatetime, status
+		FROM actions
+		WHERE status IN ('completed', 'failed')
+		AND expireafter < NOW() - ($1 || ' days')::interval
+		ON CONFLICT (id) DO NOTHING`, retainDays)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive expired actions: '%v'", err)
+	}
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count archived actions: '%v'", err)
+	}
+	_, err = w.db.c.ExecContext(ctx, `
+		DELETE FROM actions
+		WHERE status IN ('completed', 'failed')
+		AND expireafter < NOW() - ($1 || ' days')::interval
+		AND id IN (SELECT id FROM actions_archive)`, retainDays)
+	if err != nil {
+		return archived, fmt.Errorf("failed to prune archived actions: '%v'", err)
+	}
+	return archived, nil
+}
+
+// deleteStaleHeartbeats removes offline agents whose last heartbeat is
+// older than retainDays and returns the number of rows deleted.
+func (w *RetentionWorker) deleteStaleHeartbeats(ctx context.Context, retainDays int) (int64, error) {
+	res, err := w.db.c.ExecContext(ctx,
+		`DELETE FROM agents WHERE status = 'offline' AND heartbeattime < NOW() - ($1 || ' days')::interval`, retainDays)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale agent heartbeats: '%v'", err)
+	}
+	return res.RowsAffected()
+}
+
+// TryAcquireLock attempts to take the Postgres session-level advisory lock
+// identified by id without blocking. The lock belongs to the physical
+// connection that takes it, not to db's pool, so TryAcquireLock reserves a
+// single *sql.Conn for the caller and returns it; releasing the lock later
+// must go through that same conn via ReleaseLock, or the pool could hand
+// the connection to an unrelated query while it still holds the lock, or
+// route the unlock call to a different connection that never held it.
+// TryAcquireLock returns false, rather than an error, if another session
+// already holds the lock.
+func (db *DB) TryAcquireLock(ctx context.Context, id int64) (conn *sql.Conn, acquired bool, err error) {
+	conn, err = db.c.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve a connection for advisory lock %d: '%v'", id, err)
+	}
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, id).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire advisory lock %d: '%v'", id, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+	return conn, true, nil
+}
+
+// ReleaseLock releases the advisory lock identified by id on conn,
+// previously acquired with TryAcquireLock, and returns conn to the pool.
+func (db *DB) ReleaseLock(ctx context.Context, conn *sql.Conn, id int64) error {
+	defer conn.Close()
+	var released bool
+	err := conn.QueryRowContext(ctx, `SELECT pg_advisory_unlock($1)`, id).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("failed to release advisory lock %d: '%v'", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+// NewRetentionWorker just wires its arguments into the returned worker; the
+// GC queries themselves (deleteExpiredCommands, archiveExpiredActions,
+// deleteStaleHeartbeats, TryAcquireLock/ReleaseLock) all run against db.c,
+// and DB itself is declared outside this snapshot, so they aren't unit
+// testable here without a live Postgres connection.
+func TestNewRetentionWorker(t *testing.T) {
+	conf := RetentionConfig{RetainCommands: 30, RetainActions: 90, RetainHeartbeats: 7, Interval: time.Hour}
+	w := NewRetentionWorker(nil, conf)
+	if w.conf != conf {
+		t.Errorf("NewRetentionWorker: conf = %+v, want %+v", w.conf, conf)
+	}
+	if w.OnCycle != nil {
+		t.Error("NewRetentionWorker: OnCycle should default to nil")
+	}
+}
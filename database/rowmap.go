@@ -0,0 +1,76 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database /* import "mig.ninja/mig/database" */
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// Columns returns the `db:"..."` struct tag of every tagged field of T, in
+// declaration order, so a SELECT list can be generated from the same tags
+// ScanOne and ScanAll bind against. This keeps the column list and the scan
+// targets from drifting apart as fields are added, removed or reordered --
+// the bug class behind every hand-rolled rows.Scan(&x.A, &x.B, ...) call in
+// this package.
+//
+// T must be a struct type; fields without a db tag are ignored by both
+// Columns and the scan helpers below.
+func Columns[T any]() []string {
+	return columnsOf(reflect.TypeOf(*new(T)))
+}
+
+func columnsOf(t reflect.Type) []string {
+	cols := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if tag, ok := t.Field(i).Tag.Lookup("db"); ok {
+			cols = append(cols, tag)
+		}
+	}
+	return cols
+}
+
+// ScanOne scans the current row of rows into dest, binding its tagged
+// fields in the same declaration order Columns[T] reports. Callers must
+// have already advanced rows with rows.Next.
+func ScanOne[T any](rows *sql.Rows, dest *T) error {
+	targets, err := scanTargets(dest)
+	if err != nil {
+		return err
+	}
+	return rows.Scan(targets...)
+}
+
+// ScanAll drains rows into dest, appending one T per row via ScanOne, and
+// closes rows before returning.
+func ScanAll[T any](rows *sql.Rows, dest *[]T) error {
+	defer rows.Close()
+	for rows.Next() {
+		var v T
+		if err := ScanOne(rows, &v); err != nil {
+			return err
+		}
+		*dest = append(*dest, v)
+	}
+	return rows.Err()
+}
+
+// scanTargets returns addressable pointers to dest's db-tagged fields, in
+// declaration order, suitable as rows.Scan arguments.
+func scanTargets[T any](dest *T) ([]interface{}, error) {
+	v := reflect.ValueOf(dest).Elem()
+	t := v.Type()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("database: %s is not a struct", t)
+	}
+	targets := make([]interface{}, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("db"); ok {
+			targets = append(targets, v.Field(i).Addr().Interface())
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,125 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+)
+
+// fakeRowsDriver is a minimal database/sql driver that replays a fixed set
+// of columns/rows for any query, so ScanOne/ScanAll can be exercised
+// against a real *sql.Rows without a live database.
+type fakeRowsDriver struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (d *fakeRowsDriver) Open(name string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeRowsDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, io.EOF }
+
+type fakeStmt struct{ d *fakeRowsDriver }
+
+func (s fakeStmt) Close() error                                    { return nil }
+func (s fakeStmt) NumInput() int                                   { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, io.EOF }
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{cols: s.d.cols, rows: s.d.rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+func openFakeRows(t *testing.T, cols []string, rows [][]driver.Value) *sql.Rows {
+	t.Helper()
+	name := t.Name()
+	sql.Register(name, &fakeRowsDriver{cols: cols, rows: rows})
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	result, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("db.Query: %v", err)
+	}
+	return result
+}
+
+type testRow struct {
+	ID   float64 `db:"id"`
+	Name string  `db:"name"`
+}
+
+func TestColumns(t *testing.T) {
+	got := Columns[testRow]()
+	want := []string{"id", "name"}
+	if len(got) != len(want) {
+		t.Fatalf("Columns = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Columns[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestScanOne(t *testing.T) {
+	rows := openFakeRows(t, []string{"id", "name"}, [][]driver.Value{
+		{float64(1), "alice"},
+	})
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal("expected a row")
+	}
+	var got testRow
+	if err := ScanOne(rows, &got); err != nil {
+		t.Fatalf("ScanOne: unexpected error: %v", err)
+	}
+	if want := (testRow{ID: 1, Name: "alice"}); got != want {
+		t.Errorf("ScanOne: got %+v, want %+v", got, want)
+	}
+}
+
+func TestScanAll(t *testing.T) {
+	rows := openFakeRows(t, []string{"id", "name"}, [][]driver.Value{
+		{float64(1), "alice"},
+		{float64(2), "bob"},
+	})
+	var got []testRow
+	if err := ScanAll(rows, &got); err != nil {
+		t.Fatalf("ScanAll: unexpected error: %v", err)
+	}
+	want := []testRow{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}}
+	if len(got) != len(want) {
+		t.Fatalf("ScanAll: got %d rows, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ScanAll[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
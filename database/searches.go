@@ -7,36 +7,97 @@
 package database /* import "mig.ninja/mig/database" */
 
 import (
-	"database/sql"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"mig.ninja/mig"
+	"mig.ninja/mig/database/jsonquery"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// defaultStreamWorkers is the size of the worker pool used to pipeline the
+// per-row GetActionCounters/InvestigatorByActionID follow-up queries in the
+// streaming Search* variants when callers don't request a specific size.
+const defaultStreamWorkers = 4
+
+// trySendErr delivers err to errc without blocking, so a worker that hits a
+// second error after one has already been reported doesn't stall waiting for
+// a reader that only ever drains the first one.
+func trySendErr(errc chan<- error, err error) {
+	select {
+	case errc <- err:
+	default:
+	}
+}
+
 // SearchParameters contains fields used to perform database searches
 type SearchParameters struct {
-	ActionID         string    `json:"actionid"`
-	ActionName       string    `json:"actionname"`
-	After            time.Time `json:"after"`
-	AgentID          string    `json:"agentid"`
-	AgentName        string    `json:"agentname"`
-	Before           time.Time `json:"before"`
-	CommandID        string    `json:"commandid"`
-	FoundAnything    bool      `json:"foundanything"`
-	InvestigatorID   string    `json:"investigatorid"`
-	InvestigatorName string    `json:"investigatorname"`
-	Limit            float64   `json:"limit"`
-	Offset           float64   `json:"offset"`
-	Report           string    `json:"report"`
-	Status           string    `json:"status"`
-	Target           string    `json:"target"`
-	ThreatFamily     string    `json:"threatfamily"`
-	Type             string    `json:"type"`
+	ActionID    string    `json:"actionid"`
+	ActionIDs   []string  `json:"actionids,omitempty"`
+	ActionName  string    `json:"actionname"`
+	ActionNames []string  `json:"actionnames,omitempty"`
+	After       time.Time `json:"after"`
+	// AfterCreatedAt and AfterID select keyset pagination over the
+	// investigator/action search's own sort column and id, as an
+	// alternative to Cursor for callers that want to manage the keyset
+	// themselves instead of round-tripping Pagination.NextCursor's opaque
+	// token. When AfterID is nonzero or AfterCreatedAt is set they're
+	// honored in preference to Offset, but only while Cursor is empty:
+	// Cursor takes precedence over both. Unlike After/Before (a time range
+	// filter), they express "give me the page after this row".
+	AfterCreatedAt time.Time `json:"aftercreatedat,omitempty"`
+	AfterID        uint64    `json:"afterid,omitempty"`
+	AgentID        string    `json:"agentid"`
+	AgentIDs       []string  `json:"agentids,omitempty"`
+	AgentName      string    `json:"agentname"`
+	AgentNames     []string  `json:"agentnames,omitempty"`
+	Before         time.Time `json:"before"`
+	CommandID      string    `json:"commandid"`
+	CommandIDs     []string  `json:"commandids,omitempty"`
+	Cursor         string    `json:"cursor,omitempty"`
+	// FullText matches actions.name, actions.description and actions.threat
+	// through a to_tsvector/plainto_tsquery full-text search.
+	FullText          string   `json:"fulltext,omitempty"`
+	FoundAnything     bool     `json:"foundanything"`
+	HasInvestigator   bool     `json:"hasinvestigator"`
+	InvestigatorID    string   `json:"investigatorid"`
+	InvestigatorIDs   []string `json:"investigatorids,omitempty"`
+	InvestigatorName  string   `json:"investigatorname"`
+	InvestigatorNames []string `json:"investigatornames,omitempty"`
+	Limit             float64  `json:"limit"`
+	// NoInvestigator restricts results to rows with no signing
+	// investigator at all, the inverse of HasInvestigator. Setting both
+	// is contradictory; NoInvestigator takes precedence. Only SearchActions
+	// and SearchAgents read HasInvestigator and NoInvestigator:
+	// SearchCommands always requires a signing investigator, and
+	// SearchInvestigators ignores both.
+	NoInvestigator bool `json:"noinvestigator"`
+	// Offset is deprecated in favor of Cursor: OFFSET-based paging forces
+	// Postgres to scan and discard every skipped row, which falls apart past
+	// a few hundred thousand rows. Prefer Cursor for new callers; Offset is
+	// only honored when Cursor is empty.
+	Offset float64 `json:"offset"`
+	// OperationQuery is a jsonquery DSL expression (see database/jsonquery)
+	// matched against each element of actions.operations.
+	OperationQuery string `json:"operationquery,omitempty"`
+	Report         string `json:"report"`
+	// ResultQuery is a jsonquery DSL expression (see database/jsonquery)
+	// matched against each element of commands.results.
+	ResultQuery    string   `json:"resultquery,omitempty"`
+	Status         string   `json:"status"`
+	Statuses       []string `json:"statuses,omitempty"`
+	Target         string   `json:"target"`
+	ThreatFamily   string   `json:"threatfamily"`
+	ThreatFamilies []string `json:"threatfamilies,omitempty"`
+	Type           string   `json:"type"`
+	Types          []string `json:"types,omitempty"`
 }
 
 // 10 years
@@ -91,6 +152,44 @@ func (p SearchParameters) String() (query string) {
 	if p.Status != "%" {
 		query += fmt.Sprintf("&status=%s", p.Status)
 	}
+	// repeated multi-value filters are serialized as repeated query parameters,
+	// so `?agentid=1&agentid=2` round-trips through String() unchanged
+	for _, v := range p.AgentIDs {
+		query += fmt.Sprintf("&agentid=%s", v)
+	}
+	for _, v := range p.AgentNames {
+		query += fmt.Sprintf("&agentname=%s", v)
+	}
+	for _, v := range p.ActionIDs {
+		query += fmt.Sprintf("&actionid=%s", v)
+	}
+	for _, v := range p.ActionNames {
+		query += fmt.Sprintf("&actionname=%s", v)
+	}
+	for _, v := range p.CommandIDs {
+		query += fmt.Sprintf("&commandid=%s", v)
+	}
+	for _, v := range p.InvestigatorIDs {
+		query += fmt.Sprintf("&investigatorid=%s", v)
+	}
+	for _, v := range p.InvestigatorNames {
+		query += fmt.Sprintf("&investigatorname=%s", v)
+	}
+	for _, v := range p.Statuses {
+		query += fmt.Sprintf("&status=%s", v)
+	}
+	for _, v := range p.ThreatFamilies {
+		query += fmt.Sprintf("&threatfamily=%s", v)
+	}
+	for _, v := range p.Types {
+		query += fmt.Sprintf("&type=%s", v)
+	}
+	if p.HasInvestigator {
+		query += "&hasinvestigator=true"
+	}
+	if p.NoInvestigator {
+		query += "&noinvestigator=true"
+	}
 	query += fmt.Sprintf("&limit=%.0f", p.Limit)
 	if p.Offset != 0 {
 		query += fmt.Sprintf("&offset=%.0f", p.Offset)
@@ -100,10 +199,34 @@ func (p SearchParameters) String() (query string) {
 
 type IDs struct {
 	minActionID, maxActionID, minCommandID, maxCommandID, minAgentID, maxAgentID, minInvID, maxInvID float64
+	actionIDRanges, commandIDRanges, agentIDRanges, invIDRanges                                      []IDRange
+}
+
+// IDRange is a single [Min, Max] bound built from one element of a
+// multi-value ID filter (e.g. one value of AgentIDs). A plain ID turns
+// into a single-point range where Min == Max.
+type IDRange struct {
+	Min, Max float64
 }
 
 const MAXFLOAT64 float64 = 9007199254740991 // 2^53-1
 
+// idRangesFromList parses a list of decimal ID strings into single-point
+// IDRanges, used to translate multi-value filters like AgentIDs into the
+// same min/max bracket form makeIDsFromParams already produces for the
+// scalar fields.
+func idRangesFromList(values []string) (ranges []IDRange, err error) {
+	for _, v := range values {
+		var f float64
+		f, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return
+		}
+		ranges = append(ranges, IDRange{Min: f, Max: f})
+	}
+	return
+}
+
 func makeIDsFromParams(p SearchParameters) (ids IDs, err error) {
 	ids.minActionID = 0
 	ids.maxActionID = MAXFLOAT64
@@ -114,6 +237,10 @@ func makeIDsFromParams(p SearchParameters) (ids IDs, err error) {
 		}
 		ids.maxActionID = ids.minActionID
 	}
+	ids.actionIDRanges, err = idRangesFromList(p.ActionIDs)
+	if err != nil {
+		return
+	}
 	ids.minCommandID = 0
 	ids.maxCommandID = MAXFLOAT64
 	if p.CommandID != "∞" {
@@ -123,6 +250,10 @@ func makeIDsFromParams(p SearchParameters) (ids IDs, err error) {
 		}
 		ids.maxCommandID = ids.minCommandID
 	}
+	ids.commandIDRanges, err = idRangesFromList(p.CommandIDs)
+	if err != nil {
+		return
+	}
 	ids.minAgentID = 0
 	ids.maxAgentID = MAXFLOAT64
 	if p.AgentID != "∞" {
@@ -132,6 +263,10 @@ func makeIDsFromParams(p SearchParameters) (ids IDs, err error) {
 		}
 		ids.maxAgentID = ids.minAgentID
 	}
+	ids.agentIDRanges, err = idRangesFromList(p.AgentIDs)
+	if err != nil {
+		return
+	}
 	ids.minInvID = 0
 	ids.maxInvID = MAXFLOAT64
 	if p.InvestigatorID != "∞" {
@@ -141,731 +276,1564 @@ func makeIDsFromParams(p SearchParameters) (ids IDs, err error) {
 		}
 		ids.maxInvID = ids.minInvID
 	}
+	ids.invIDRanges, err = idRangesFromList(p.InvestigatorIDs)
+	if err != nil {
+		return
+	}
 	return
 }
 
-// SearchCommands returns an array of commands that match search parameters
-func (db *DB) SearchCommands(p SearchParameters, doFoundAnything bool) (commands []mig.Command, err error) {
-	var (
-		rows *sql.Rows
-	)
-	ids, err := makeIDsFromParams(p)
-	if err != nil {
+// appendIDRangesWhere ANDs in a disjunction of per-element ID ranges,
+// e.g. `(actions.id >= $1 AND actions.id <= $2) OR (actions.id >= $3 AND actions.id <= $4)`,
+// so a multi-value ID filter behaves like a SQL IN (...) without requiring
+// array support in the underlying driver.
+func appendIDRangesWhere(driver Driver, where *string, vals *[]interface{}, valctr *int, column string, ranges []IDRange) {
+	if len(ranges) == 0 {
 		return
 	}
-	query := `SELECT commands.id, commands.status, commands.results, commands.starttime, commands.finishtime,
-			actions.id, actions.name, actions.target, actions.description, actions.threat,
-			actions.operations, actions.validfrom, actions.expireafter, actions.pgpsignatures,
-			actions.syntaxversion, agents.id, agents.name, agents.version, agents.tags, agents.environment
-		FROM	commands
-			INNER JOIN actions ON ( commands.actionid = actions.id)
-			INNER JOIN signatures ON ( actions.id = signatures.actionid )
-			INNER JOIN investigators ON ( signatures.investigatorid = investigators.id )
-			INNER JOIN agents ON ( commands.agentid = agents.id )
-		WHERE `
-	vals := []interface{}{}
-	valctr := 0
-	if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
-		query += fmt.Sprintf(`commands.starttime <= $%d `, valctr+1)
-		vals = append(vals, p.Before)
-		valctr += 1
-	}
-	if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`commands.starttime >= $%d `, valctr+1)
-		vals = append(vals, p.After)
-		valctr += 1
+	if *valctr > 0 {
+		*where += " AND "
 	}
-	if p.CommandID != "∞" {
-		if valctr > 0 {
-			query += " AND "
+	*where += "("
+	for i, r := range ranges {
+		if i > 0 {
+			*where += " OR "
 		}
-		query += fmt.Sprintf(`commands.id >= $%d AND commands.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minCommandID, ids.maxCommandID)
-		valctr += 2
+		*where += fmt.Sprintf(`(%s >= %s AND %s <= %s)`,
+			column, driver.Placeholder(*valctr+1), column, driver.Placeholder(*valctr+2))
+		*vals = append(*vals, r.Min, r.Max)
+		*valctr += 2
 	}
-	if p.Status != "%" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`commands.status ILIKE $%d`, valctr+1)
-		vals = append(vals, p.Status)
-		valctr += 1
+	*where += ")"
+}
+
+// appendILikeAnyWhere ANDs in a `column ILIKE ANY($n)` predicate that
+// matches any of the given case-insensitive patterns, translating a
+// multi-value name/status/type filter into a single parameterized clause.
+func appendILikeAnyWhere(driver Driver, where *string, vals *[]interface{}, valctr *int, column string, values []string) {
+	if len(values) == 0 {
+		return
 	}
-	if p.ActionID != "∞" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`actions.id >= $%d AND actions.id <= $%d`, valctr+1, valctr+2)
-		vals = append(vals, ids.minActionID, ids.maxActionID)
-		valctr += 2
+	if *valctr > 0 {
+		*where += " AND "
 	}
-	if p.ActionName != "%" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`actions.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.ActionName)
-		valctr += 1
+	*where += fmt.Sprintf(`%s ILIKE ANY(%s)`,
+		column, driver.Placeholder(*valctr+1))
+	*vals = append(*vals, pq.Array(values))
+	*valctr += 1
+}
+
+// TagFilter is a single agent tag key/value pair used to scope a
+// tag-restricted investigator's view of the agents table.
+type TagFilter struct {
+	Key   string
+	Value string
+}
+
+// Permissions describes what the calling investigator is allowed to see in
+// a database search. Administrators see everything; everyone else is scoped
+// to rows signed by their own investigator id and, if AllowedTags is set,
+// additionally allowed rows on agents carrying all of those tags (see
+// applyAuthScope). Passing
+// Permissions to Search* bakes this scoping directly into the generated
+// query instead of relying on API handlers to post-filter results.
+type Permissions struct {
+	InvestigatorID float64
+	IsAdmin        bool
+	AllowedTags    []TagFilter
+}
+
+// applyAuthScope ANDs the mandatory authorization predicate for auth into an
+// existing WHERE clause fragment. Admins are left unrestricted. A non-admin
+// is scoped to investigators.id = their own id; if auth.AllowedTags is also
+// set, that's widened to an OR, so a tag-scoped investigator sees rows they
+// personally signed PLUS any agent carrying all of the allowed tags,
+// regardless of who signed it -- AllowedTags grants additional visibility,
+// it doesn't narrow what investigators.id already allows.
+func applyAuthScope(driver Driver, where string, vals []interface{}, valctr int, auth Permissions) (string, []interface{}, int) {
+	if auth.IsAdmin {
+		return where, vals, valctr
+	}
+	if valctr > 0 {
+		where += " AND "
+	}
+	authPredicate := fmt.Sprintf(`investigators.id = %s`, driver.Placeholder(valctr+1))
+	vals = append(vals, auth.InvestigatorID)
+	valctr += 1
+	if len(auth.AllowedTags) > 0 {
+		tagPredicate := ""
+		for i, tag := range auth.AllowedTags {
+			if i > 0 {
+				tagPredicate += " AND "
+			}
+			tagJSON, _ := json.Marshal(map[string]string{tag.Key: tag.Value})
+			tagPredicate += fmt.Sprintf(`agents.tags @> %s`, driver.Placeholder(valctr+1))
+			vals = append(vals, tagJSON)
+			valctr += 1
+		}
+		authPredicate = fmt.Sprintf(`(%s OR (%s))`, authPredicate, tagPredicate)
+	}
+	where += authPredicate
+	return where, vals, valctr
+}
+
+// appendJSONPathWhere ANDs in a `column @? $n` predicate compiled from a
+// jsonquery DSL expression, used to match SearchParameters.ResultQuery
+// against commands.results and OperationQuery against actions.operations.
+func appendJSONPathWhere(driver Driver, where *string, vals *[]interface{}, valctr *int, column, dslExpr string) error {
+	if dslExpr == "" {
+		return nil
+	}
+	q, err := jsonquery.Parse(dslExpr)
+	if err != nil {
+		return fmt.Errorf("invalid query %q for %s: %v", dslExpr, column, err)
 	}
-	if p.InvestigatorID != "∞" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`investigators.id >= $%d AND investigators.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minInvID, ids.maxInvID)
-		valctr += 2
+	if *valctr > 0 {
+		*where += " AND "
 	}
-	if p.InvestigatorName != "%" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`investigators.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.InvestigatorName)
-		valctr += 1
+	*where += fmt.Sprintf(`%s @? %s`,
+		column, driver.Placeholder(*valctr+1))
+	*vals = append(*vals, q.Jsonpath())
+	*valctr += 1
+	return nil
+}
+
+// appendFullTextWhere ANDs in a to_tsvector/plainto_tsquery full-text match
+// over an action's name, description and threat, backed by the expression
+// index created in database/migrations.
+func appendFullTextWhere(driver Driver, where *string, vals *[]interface{}, valctr *int, fulltext string) {
+	if fulltext == "" {
+		return
 	}
-	if p.AgentID != "∞" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`agents.id >= $%d AND agents.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minAgentID, ids.maxAgentID)
-		valctr += 2
+	if *valctr > 0 {
+		*where += " AND "
 	}
-	if p.AgentName != "%" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`agents.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.AgentName)
-		valctr += 1
+	*where += fmt.Sprintf(
+		`to_tsvector('english', actions.name || ' ' || actions.description::text || ' ' || actions.threat::text) @@ plainto_tsquery('english', %s)`,
+		driver.Placeholder(*valctr+1))
+	*vals = append(*vals, fulltext)
+	*valctr += 1
+}
+
+// Pagination carries paging metadata returned alongside a Search* result.
+// NextCursor is empty once the result set is exhausted. Total is a cheap,
+// approximate row count for the searched table and is left at 0 if the
+// estimate could not be computed; it is not meant to be exact.
+type Pagination struct {
+	NextCursor string
+	Total      int64
+}
+
+// encodeCursor serializes a keyset position into the opaque, base64 token
+// handed back to callers as Pagination.NextCursor.
+func encodeCursor(v interface{}) (string, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", err
 	}
-	if doFoundAnything {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`commands.status = $%d
-			AND commands.id IN (	SELECT commands.id FROM commands, actions, json_array_elements(commands.results) as r
-						WHERE commands.actionid=actions.id
-						AND actions.id >= $%d AND actions.id <= $%d
-						AND r#>>'{foundanything}' = $%d) `,
-			valctr+1, valctr+2, valctr+3, valctr+4)
-		vals = append(vals, mig.StatusSuccess, ids.minActionID, ids.maxActionID, p.FoundAnything)
-		valctr += 4
+	return base64.URLEncoding.EncodeToString(buf), nil
+}
+
+// decodeCursor parses a token previously produced by encodeCursor back into
+// the keyset position used to resume a search with SearchParameters.Cursor.
+func decodeCursor(cursor string, v interface{}) error {
+	buf, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("invalid cursor encoding: %v", err)
 	}
-	if p.ThreatFamily != "%" {
-		if valctr > 0 {
-			query += " AND "
-		}
-		query += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE $%d `, valctr+1)
-		vals = append(vals, p.ThreatFamily)
-		valctr += 1
+	if err := json.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("invalid cursor content: %v", err)
 	}
-	query += fmt.Sprintf(` GROUP BY commands.id, actions.id, agents.id
-		ORDER BY commands.starttime DESC LIMIT $%d OFFSET $%d;`, valctr+1, valctr+2)
-	vals = append(vals, uint64(p.Limit), uint64(p.Offset))
+	return nil
+}
 
-	stmt, err := db.c.Prepare(query)
-	if stmt != nil {
-		defer stmt.Close()
+// estimateRowCount returns a fast, approximate row count for a table using
+// Postgres's planner statistics (pg_class.reltuples) rather than a full
+// COUNT(*), which becomes prohibitively expensive against multi-million row
+// tables like commands. The estimate is refreshed by autovacuum/ANALYZE and
+// is only meant to size a "Total" hint in Pagination, not for exact counts.
+// Drivers other than postgres fall back to an exact COUNT(*).
+func estimateRowCount(db *DB, driver Driver, table string) (total int64, err error) {
+	if driver.Name() != "postgres" {
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, driver.QuoteIdent(table))
+		begin := time.Now()
+		err = db.c.QueryRow(query).Scan(&total)
+		defaultLogger.Trace(context.Background(), begin, query, nil, 1, err)
+		return
 	}
+	var reltuples float64
+	query := fmt.Sprintf(`SELECT reltuples FROM pg_class WHERE relname = %s`, driver.Placeholder(1))
+	begin := time.Now()
+	err = db.c.QueryRow(query, table).Scan(&reltuples)
+	defaultLogger.Trace(context.Background(), begin, query, []interface{}{table}, 1, err)
 	if err != nil {
-		err = fmt.Errorf("Error while preparing search statement: '%v' in '%s'", err, query)
-		return
+		return 0, err
 	}
-	rows, err = stmt.Query(vals...)
-	if rows != nil {
-		defer rows.Close()
+	return int64(reltuples), nil
+}
+
+type commandCursor struct {
+	StartTime time.Time `json:"starttime"`
+	ID        float64   `json:"id"`
+}
+
+type actionCursor struct {
+	ValidFrom time.Time `json:"validfrom"`
+	ID        float64   `json:"id"`
+}
+
+type agentCursor struct {
+	HeartBeatTime time.Time `json:"heartbeattime"`
+	ID            float64   `json:"id"`
+}
+
+type investigatorCursor struct {
+	LastModified time.Time `json:"lastmodified"`
+	ID           float64   `json:"id"`
+}
+
+// SearchCommands returns an array of commands that match search parameters.
+// When p.Cursor is set, the result is paged by keyset instead of OFFSET, and
+// pagination.NextCursor carries the token for the following page (empty once
+// the result set is exhausted). It buffers the full result set in memory;
+// callers that expect a wide result set should prefer SearchCommandsStream.
+func (db *DB) SearchCommands(p SearchParameters, doFoundAnything bool, auth Permissions) (commands []mig.Command, pagination Pagination, err error) {
+	ctx := context.Background()
+	driver := defaultDriver
+	// a single worker keeps rows in query order, so the last element below
+	// is the last row scanned and a valid keyset position
+	cmdChan, errChan := db.SearchCommandsStream(ctx, p, doFoundAnything, auth, 1)
+	for cmd := range cmdChan {
+		commands = append(commands, cmd)
 	}
-	if err != nil {
-		err = fmt.Errorf("Error while finding commands: '%v'", err)
+	if err = <-errChan; err != nil {
 		return
 	}
-	for rows.Next() {
-		var jRes, jDesc, jThreat, jOps, jSig, jAgtTags, jAgtEnv []byte
-		var cmd mig.Command
-		err = rows.Scan(&cmd.ID, &cmd.Status, &jRes, &cmd.StartTime, &cmd.FinishTime,
-			&cmd.Action.ID, &cmd.Action.Name, &cmd.Action.Target, &jDesc, &jThreat, &jOps,
-			&cmd.Action.ValidFrom, &cmd.Action.ExpireAfter, &jSig, &cmd.Action.SyntaxVersion,
-			&cmd.Agent.ID, &cmd.Agent.Name, &cmd.Agent.Version, &jAgtTags, &jAgtEnv)
-		if err != nil {
-			err = fmt.Errorf("Failed to retrieve command: '%v'", err)
-			return
-		}
-		err = json.Unmarshal(jThreat, &cmd.Action.Threat)
+	useCursor := p.Cursor != ""
+	if useCursor && len(commands) == int(p.Limit) {
+		last := commands[len(commands)-1]
+		pagination.NextCursor, err = encodeCursor(commandCursor{StartTime: last.StartTime, ID: last.ID})
 		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action threat: '%v'", err)
 			return
 		}
-		err = json.Unmarshal(jRes, &cmd.Results)
-		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal command results: '%v'", err)
-			return
-		}
-		err = json.Unmarshal(jDesc, &cmd.Action.Description)
-		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action description: '%v'", err)
-			return
-		}
-		err = json.Unmarshal(jOps, &cmd.Action.Operations)
+	}
+	if total, esterr := estimateRowCount(db, driver, "commands"); esterr == nil {
+		pagination.Total = total
+	}
+	return
+}
+
+// SearchCommandsStream is the streaming counterpart to SearchCommands: it
+// pushes commands onto the returned channel as rows.Next() yields them
+// instead of buffering the whole result set, and pipelines the per-command
+// GetActionCounters/InvestigatorByActionID follow-up queries through a pool
+// of workers goroutines (defaultStreamWorkers if workers < 1). Cancelling
+// ctx, e.g. on an HTTP client disconnect, stops the query and closes both
+// channels. Both channels are closed once the stream is exhausted; the
+// caller should drain the error channel after the command channel closes.
+func (db *DB) SearchCommandsStream(ctx context.Context, p SearchParameters, doFoundAnything bool, auth Permissions, workers int) (<-chan mig.Command, <-chan error) {
+	out := make(chan mig.Command)
+	errc := make(chan error, 1)
+	if workers < 1 {
+		workers = defaultStreamWorkers
+	}
+	driver := defaultDriver
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		var cursor commandCursor
+		useCursor := p.Cursor != ""
+		if useCursor {
+			if err := decodeCursor(p.Cursor, &cursor); err != nil {
+				trySendErr(errc, err)
+				return
+			}
+		}
+		ids, err := makeIDsFromParams(p)
 		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action operations: '%v'", err)
+			trySendErr(errc, err)
 			return
 		}
-		err = json.Unmarshal(jSig, &cmd.Action.PGPSignatures)
-		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action signatures: '%v'", err)
+		query := `SELECT commands.id, commands.status, commands.results, commands.starttime, commands.finishtime,
+			actions.id, actions.name, actions.target, actions.description, actions.threat,
+			actions.operations, actions.validfrom, actions.expireafter, actions.pgpsignatures,
+			actions.syntaxversion, agents.id, agents.name, agents.version, agents.tags, agents.environment
+		FROM	commands
+			INNER JOIN actions ON ( commands.actionid = actions.id)
+			INNER JOIN signatures ON ( actions.id = signatures.actionid )
+			INNER JOIN investigators ON ( signatures.investigatorid = investigators.id )
+			INNER JOIN agents ON ( commands.agentid = agents.id )
+		WHERE `
+		vals := []interface{}{}
+		valctr := 0
+		if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
+			query += fmt.Sprintf(`commands.starttime <= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Before)
+			valctr += 1
+		}
+		if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`commands.starttime >= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.After)
+			valctr += 1
+		}
+		if p.CommandID != "∞" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`commands.id >= %s AND commands.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minCommandID, ids.maxCommandID)
+			valctr += 2
+		}
+		if p.Status != "%" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`commands.status ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Status)
+			valctr += 1
+		}
+		if p.ActionID != "∞" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`actions.id >= %s AND actions.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minActionID, ids.maxActionID)
+			valctr += 2
+		}
+		if p.ActionName != "%" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`actions.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ActionName)
+			valctr += 1
+		}
+		if p.InvestigatorID != "∞" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`investigators.id >= %s AND investigators.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minInvID, ids.maxInvID)
+			valctr += 2
+		}
+		if p.InvestigatorName != "%" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`investigators.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.InvestigatorName)
+			valctr += 1
+		}
+		if p.AgentID != "∞" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`agents.id >= %s AND agents.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minAgentID, ids.maxAgentID)
+			valctr += 2
+		}
+		if p.AgentName != "%" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`agents.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.AgentName)
+			valctr += 1
+		}
+		if doFoundAnything {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`commands.status = %s
+			AND commands.id IN (	SELECT commands.id FROM commands, actions, json_array_elements(commands.results) as r
+						WHERE commands.actionid=actions.id
+						AND actions.id >= %s AND actions.id <= %s
+						AND r#>>'{foundanything}' = %s) `,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2), driver.Placeholder(valctr+3), driver.Placeholder(valctr+4))
+			vals = append(vals, mig.StatusSuccess, ids.minActionID, ids.maxActionID, p.FoundAnything)
+			valctr += 4
+		}
+		if p.ThreatFamily != "%" {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ThreatFamily)
+			valctr += 1
+		}
+		appendIDRangesWhere(driver, &query, &vals, &valctr, "commands.id", ids.commandIDRanges)
+		appendIDRangesWhere(driver, &query, &vals, &valctr, "actions.id", ids.actionIDRanges)
+		appendIDRangesWhere(driver, &query, &vals, &valctr, "agents.id", ids.agentIDRanges)
+		appendIDRangesWhere(driver, &query, &vals, &valctr, "investigators.id", ids.invIDRanges)
+		appendILikeAnyWhere(driver, &query, &vals, &valctr, "commands.status", p.Statuses)
+		appendILikeAnyWhere(driver, &query, &vals, &valctr, "actions.name", p.ActionNames)
+		appendILikeAnyWhere(driver, &query, &vals, &valctr, "agents.name", p.AgentNames)
+		appendILikeAnyWhere(driver, &query, &vals, &valctr, "investigators.name", p.InvestigatorNames)
+		appendILikeAnyWhere(driver, &query, &vals, &valctr, "actions.threat#>>'{family}'", p.ThreatFamilies)
+		if err = appendJSONPathWhere(driver, &query, &vals, &valctr, "commands.results", p.ResultQuery); err != nil {
+			trySendErr(errc, err)
 			return
 		}
-		err = json.Unmarshal(jAgtTags, &cmd.Agent.Tags)
-		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal agent tags: '%v'", err)
-			return
+		appendFullTextWhere(driver, &query, &vals, &valctr, p.FullText)
+		query, vals, valctr = applyAuthScope(driver, query, vals, valctr, auth)
+		if useCursor {
+			if valctr > 0 {
+				query += " AND "
+			}
+			query += fmt.Sprintf(`(commands.starttime, commands.id) < (%s, %s)`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, cursor.StartTime, cursor.ID)
+			valctr += 2
+		}
+		query += ` GROUP BY commands.id, actions.id, agents.id
+		ORDER BY commands.starttime DESC `
+		if useCursor {
+			query += fmt.Sprintf(`LIMIT %s;`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, uint64(p.Limit))
+		} else {
+			query += fmt.Sprintf(`LIMIT %s OFFSET %s;`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, uint64(p.Limit), uint64(p.Offset))
 		}
-		err = json.Unmarshal(jAgtEnv, &cmd.Agent.Env)
+
+		begin := time.Now()
+		rows, err := db.c.QueryContext(ctx, query, vals...)
+		defaultLogger.Trace(ctx, begin, query, vals, -1, err)
 		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal agent environment: '%v'", err)
+			trySendErr(errc, fmt.Errorf("Error while finding commands: '%v'", err))
 			return
 		}
-		cmd.Action.Counters, err = db.GetActionCounters(cmd.Action.ID)
-		if err != nil {
-			err = fmt.Errorf("Failed to retrieve action counters: '%v'", err)
-			return
+		defer rows.Close()
+
+		type rawCommand struct {
+			cmd                                                 mig.Command
+			jRes, jDesc, jThreat, jOps, jSig, jAgtTags, jAgtEnv []byte
 		}
-		cmd.Action.Investigators, err = db.InvestigatorByActionID(cmd.Action.ID)
+		raw := make(chan rawCommand)
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(raw)
+			for rows.Next() {
+				var r rawCommand
+				serr := rows.Scan(&r.cmd.ID, &r.cmd.Status, &r.jRes, &r.cmd.StartTime, &r.cmd.FinishTime,
+					&r.cmd.Action.ID, &r.cmd.Action.Name, &r.cmd.Action.Target, &r.jDesc, &r.jThreat, &r.jOps,
+					&r.cmd.Action.ValidFrom, &r.cmd.Action.ExpireAfter, &r.jSig, &r.cmd.Action.SyntaxVersion,
+					&r.cmd.Agent.ID, &r.cmd.Agent.Name, &r.cmd.Agent.Version, &r.jAgtTags, &r.jAgtEnv)
+				if serr != nil {
+					trySendErr(errc, fmt.Errorf("Failed to retrieve command: '%v'", serr))
+					return
+				}
+				select {
+				case raw <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if rerr := rows.Err(); rerr != nil {
+				trySendErr(errc, fmt.Errorf("Failed to complete database query: '%v'", rerr))
+			}
+		}()
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range raw {
+					cmd := r.cmd
+					if uerr := json.Unmarshal(r.jThreat, &cmd.Action.Threat); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action threat: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jRes, &cmd.Results); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal command results: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jDesc, &cmd.Action.Description); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action description: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jOps, &cmd.Action.Operations); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action operations: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jSig, &cmd.Action.PGPSignatures); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action signatures: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jAgtTags, &cmd.Agent.Tags); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal agent tags: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jAgtEnv, &cmd.Agent.Env); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal agent environment: '%v'", uerr))
+						continue
+					}
+					var cerr error
+					cmd.Action.Counters, cerr = db.GetActionCounters(cmd.Action.ID)
+					if cerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to retrieve action counters: '%v'", cerr))
+						continue
+					}
+					cmd.Action.Investigators, cerr = db.InvestigatorByActionID(cmd.Action.ID)
+					if cerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to retrieve action investigators: '%v'", cerr))
+						continue
+					}
+					select {
+					case out <- cmd:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return out, errc
+}
+
+// SearchActions returns an array of actions that match search parameters.
+// When p.Cursor is set, the result is paged by keyset instead of OFFSET, and
+// pagination.NextCursor carries the token for the following page (empty once
+// the result set is exhausted). p.AfterID/p.AfterCreatedAt select the same
+// keyset paging in the opposite (ascending) direction for callers that track
+// the keyset themselves instead of round-tripping Cursor; they're ignored
+// when p.Cursor is set.
+func (db *DB) SearchActions(p SearchParameters, auth Permissions) (actions []mig.Action, pagination Pagination, err error) {
+	ctx := context.Background()
+	driver := defaultDriver
+	// a single worker keeps rows in query order (see SearchCommands)
+	actionChan, errChan := db.SearchActionsStream(ctx, p, auth, 1)
+	for a := range actionChan {
+		actions = append(actions, a)
+	}
+	if err = <-errChan; err != nil {
+		return
+	}
+	useCursor := p.Cursor != ""
+	// NextCursor only ever encodes a descending (Cursor-style) keyset token;
+	// a caller paging via AfterID/AfterCreatedAt already tracks the
+	// ascending keyset itself (see the doc comment above) and would silently
+	// flip direction if it round-tripped this token back as p.Cursor, so
+	// NextCursor is left empty on that path instead of handing back a token
+	// that means something different than what it looks like.
+	if useCursor && len(actions) == int(p.Limit) {
+		last := actions[len(actions)-1]
+		pagination.NextCursor, err = encodeCursor(actionCursor{ValidFrom: last.ValidFrom, ID: last.ID})
 		if err != nil {
-			err = fmt.Errorf("Failed to retrieve action investigators: '%v'", err)
 			return
 		}
-		commands = append(commands, cmd)
 	}
-	if err := rows.Err(); err != nil {
-		err = fmt.Errorf("Failed to complete database query: '%v'", err)
+	if total, esterr := estimateRowCount(db, driver, "actions"); esterr == nil {
+		pagination.Total = total
 	}
 	return
 }
 
-// SearchActions returns an array of actions that match search parameters
-func (db *DB) SearchActions(p SearchParameters) (actions []mig.Action, err error) {
-	var (
-		rows                                     *sql.Rows
-		joinAgent, joinInvestigator, joinCommand bool = false, false, false
-	)
-	ids, err := makeIDsFromParams(p)
-	if err != nil {
-		return
-	}
-	columns := `actions.id, actions.name, actions.target,  actions.description, actions.threat, actions.operations,
-		actions.validfrom, actions.expireafter, actions.starttime, actions.finishtime, actions.lastupdatetime,
-		actions.status, actions.pgpsignatures, actions.syntaxversion `
-	join := ""
-	where := ""
-	vals := []interface{}{}
-	valctr := 0
-	if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
-		where += fmt.Sprintf(`actions.expireafter <= $%d `, valctr+1)
-		vals = append(vals, p.Before)
-		valctr += 1
-	}
-	if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.validfrom >= $%d `, valctr+1)
-		vals = append(vals, p.After)
-		valctr += 1
-	}
-	if p.Status != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`action.status ILIKE $%d`, valctr+1)
-		vals = append(vals, p.Status)
-		valctr += 1
-	}
-	if p.ActionID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.id >= $%d AND actions.id <= $%d`, valctr+1, valctr+2)
-		vals = append(vals, ids.minActionID, ids.maxActionID)
-		valctr += 2
-	}
-	if p.ActionName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.ActionName)
-		valctr += 1
-	}
-	if p.InvestigatorID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.id >= $%d AND investigators.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minInvID, ids.maxInvID)
-		valctr += 2
-		joinInvestigator = true
-	}
-	if p.InvestigatorName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.InvestigatorName)
-		valctr += 1
-		joinInvestigator = true
-	}
-	if p.AgentID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.id >= $%d AND agents.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minAgentID, ids.maxAgentID)
-		valctr += 2
-		joinAgent = true
-		joinCommand = true
-	}
-	if p.AgentName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.AgentName)
-		valctr += 1
-		joinAgent = true
-		joinCommand = true
-	}
-	if p.CommandID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`commands.id >= $%d AND commands.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minCommandID, ids.maxCommandID)
-		valctr += 2
-		joinCommand = true
-	}
-	if joinCommand {
-		join += "INNER JOIN commands ON ( commands.actionid = actions.id) "
-	}
-	if joinAgent {
-		join += " INNER JOIN agents ON ( commands.agentid = agents.id ) "
-	}
-	if joinInvestigator {
-		join += ` INNER JOIN signatures ON ( actions.id = signatures.actionid )
-			INNER JOIN investigators ON ( signatures.investigatorid = investigators.id ) `
-	}
-	if p.ThreatFamily != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE $%d `, valctr+1)
-		vals = append(vals, p.ThreatFamily)
-		valctr += 1
-	}
-	query := fmt.Sprintf(`SELECT %s FROM actions %s WHERE %s GROUP BY actions.id
-		ORDER BY actions.validfrom DESC LIMIT $%d OFFSET $%d;`,
-		columns, join, where, valctr+1, valctr+2)
-	vals = append(vals, uint64(p.Limit), uint64(p.Offset))
+// SearchActionsStream is the streaming counterpart to SearchActions: it
+// pushes actions onto the returned channel as rows.Next() yields them
+// instead of buffering the whole result set, and pipelines the per-action
+// GetActionCounters/InvestigatorByActionID follow-up queries through a pool
+// of workers goroutines (defaultStreamWorkers if workers < 1). Cancelling
+// ctx stops the query and closes both channels.
+func (db *DB) SearchActionsStream(ctx context.Context, p SearchParameters, auth Permissions, workers int) (<-chan mig.Action, <-chan error) {
+	out := make(chan mig.Action)
+	errc := make(chan error, 1)
+	if workers < 1 {
+		workers = defaultStreamWorkers
+	}
+	driver := defaultDriver
 
-	stmt, err := db.c.Prepare(query)
-	if stmt != nil {
-		defer stmt.Close()
-	}
-	if err != nil {
-		err = fmt.Errorf("Error while preparing search statement: '%v' in '%s'", err, query)
-		return
-	}
-	rows, err = stmt.Query(vals...)
-	if rows != nil {
-		defer rows.Close()
-	}
-	if err != nil {
-		err = fmt.Errorf("Error while finding actions: '%v'", err)
-		return
-	}
-	for rows.Next() {
-		var jDesc, jThreat, jOps, jSig []byte
-		var a mig.Action
-		err = rows.Scan(&a.ID, &a.Name, &a.Target,
-			&jDesc, &jThreat, &jOps, &a.ValidFrom, &a.ExpireAfter,
-			&a.StartTime, &a.FinishTime, &a.LastUpdateTime, &a.Status,
-			&jSig, &a.SyntaxVersion)
-		if err != nil {
-			err = fmt.Errorf("Error while retrieving action: '%v'", err)
-			return
-		}
-		err = json.Unmarshal(jThreat, &a.Threat)
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		var (
+			joinAgent, joinInvestigator, joinCommand bool = false, false, false
+			cursor                                   actionCursor
+		)
+		useCursor := p.Cursor != ""
+		if useCursor {
+			if err := decodeCursor(p.Cursor, &cursor); err != nil {
+				trySendErr(errc, err)
+				return
+			}
+		}
+		useAfter := !useCursor && (p.AfterID != 0 || !p.AfterCreatedAt.IsZero())
+		ids, err := makeIDsFromParams(p)
 		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action threat: '%v'", err)
+			trySendErr(errc, err)
 			return
 		}
-		err = json.Unmarshal(jDesc, &a.Description)
-		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action description: '%v'", err)
+		columns := `actions.id, actions.name, actions.target,  actions.description, actions.threat, actions.operations,
+		actions.validfrom, actions.expireafter, actions.starttime, actions.finishtime, actions.lastupdatetime,
+		actions.status, actions.pgpsignatures, actions.syntaxversion `
+		join := ""
+		where := ""
+		vals := []interface{}{}
+		valctr := 0
+		if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
+			where += fmt.Sprintf(`actions.expireafter <= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Before)
+			valctr += 1
+		}
+		if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.validfrom >= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.After)
+			valctr += 1
+		}
+		if p.Status != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`action.status ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Status)
+			valctr += 1
+		}
+		if p.ActionID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.id >= %s AND actions.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minActionID, ids.maxActionID)
+			valctr += 2
+		}
+		if p.ActionName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ActionName)
+			valctr += 1
+		}
+		if p.InvestigatorID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.id >= %s AND investigators.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minInvID, ids.maxInvID)
+			valctr += 2
+			joinInvestigator = true
+		}
+		if p.InvestigatorName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.InvestigatorName)
+			valctr += 1
+			joinInvestigator = true
+		}
+		if p.AgentID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.id >= %s AND agents.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minAgentID, ids.maxAgentID)
+			valctr += 2
+			joinAgent = true
+			joinCommand = true
+		}
+		if p.AgentName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.AgentName)
+			valctr += 1
+			joinAgent = true
+			joinCommand = true
+		}
+		if p.CommandID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`commands.id >= %s AND commands.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minCommandID, ids.maxCommandID)
+			valctr += 2
+			joinCommand = true
+		}
+		if len(ids.agentIDRanges) > 0 || len(p.AgentNames) > 0 {
+			joinAgent = true
+			joinCommand = true
+		}
+		if len(ids.commandIDRanges) > 0 {
+			joinCommand = true
+		}
+		if len(ids.invIDRanges) > 0 || len(p.InvestigatorNames) > 0 || p.HasInvestigator || p.NoInvestigator {
+			joinInvestigator = true
+		}
+		if !auth.IsAdmin {
+			joinInvestigator = true
+			if len(auth.AllowedTags) > 0 {
+				joinAgent = true
+				joinCommand = true
+			}
+		}
+		if joinCommand {
+			join += "INNER JOIN commands ON ( commands.actionid = actions.id) "
+		}
+		if joinAgent {
+			join += " INNER JOIN agents ON ( commands.agentid = agents.id ) "
+		}
+		if joinInvestigator {
+			// NoInvestigator asks for actions that have NO signing
+			// investigator at all, which an INNER JOIN can never produce
+			// (it only emits matched rows) -- LEFT JOIN plus an
+			// investigators.id IS NULL predicate is required instead.
+			investigatorJoinType := "INNER"
+			if p.NoInvestigator {
+				investigatorJoinType = "LEFT"
+			}
+			join += fmt.Sprintf(` %s JOIN signatures ON ( actions.id = signatures.actionid )
+			%s JOIN investigators ON ( signatures.investigatorid = investigators.id ) `,
+				investigatorJoinType, investigatorJoinType)
+		}
+		if p.ThreatFamily != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ThreatFamily)
+			valctr += 1
+		}
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "actions.id", ids.actionIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "agents.id", ids.agentIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "commands.id", ids.commandIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "investigators.id", ids.invIDRanges)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.name", p.ActionNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "agents.name", p.AgentNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "investigators.name", p.InvestigatorNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.status", p.Statuses)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.threat#>>'{family}'", p.ThreatFamilies)
+		if err = appendJSONPathWhere(driver, &where, &vals, &valctr, "actions.operations", p.OperationQuery); err != nil {
+			trySendErr(errc, err)
 			return
 		}
-		err = json.Unmarshal(jOps, &a.Operations)
-		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action operations: '%v'", err)
-			return
+		appendFullTextWhere(driver, &where, &vals, &valctr, p.FullText)
+		where, vals, valctr = applyAuthScope(driver, where, vals, valctr, auth)
+		if useCursor {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`(actions.validfrom, actions.id) < (%s, %s)`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, cursor.ValidFrom, cursor.ID)
+			valctr += 2
+		} else if useAfter {
+			if valctr > 0 {
+				where += " AND "
+			}
+			if !p.AfterCreatedAt.IsZero() {
+				where += fmt.Sprintf(`(actions.validfrom, actions.id) > (%s, %s)`,
+					driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+				vals = append(vals, p.AfterCreatedAt, p.AfterID)
+				valctr += 2
+			} else {
+				where += fmt.Sprintf(`actions.id > %s`, driver.Placeholder(valctr+1))
+				vals = append(vals, p.AfterID)
+				valctr++
+			}
+		}
+		if p.NoInvestigator {
+			// NoInvestigator binds no placeholder, so it's appended last,
+			// after every valctr-counted predicate above has already
+			// decided its own " AND " prefix.
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += "investigators.id IS NULL"
+		}
+		var query string
+		switch {
+		case useCursor:
+			query = fmt.Sprintf(`SELECT %s FROM actions %s WHERE %s GROUP BY actions.id
+			ORDER BY actions.validfrom DESC LIMIT %s;`,
+				columns, join, where, driver.Placeholder(valctr+1))
+			vals = append(vals, uint64(p.Limit))
+		case useAfter:
+			query = fmt.Sprintf(`SELECT %s FROM actions %s WHERE %s GROUP BY actions.id
+			ORDER BY actions.validfrom ASC LIMIT %s;`,
+				columns, join, where, driver.Placeholder(valctr+1))
+			vals = append(vals, uint64(p.Limit))
+		default:
+			query = fmt.Sprintf(`SELECT %s FROM actions %s WHERE %s GROUP BY actions.id
+			ORDER BY actions.validfrom DESC LIMIT %s OFFSET %s;`,
+				columns, join, where, driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, uint64(p.Limit), uint64(p.Offset))
 		}
-		err = json.Unmarshal(jSig, &a.PGPSignatures)
+
+		begin := time.Now()
+		rows, err := db.c.QueryContext(ctx, query, vals...)
+		defaultLogger.Trace(ctx, begin, query, vals, -1, err)
 		if err != nil {
-			err = fmt.Errorf("Failed to unmarshal action signatures: '%v'", err)
+			trySendErr(errc, fmt.Errorf("Error while finding actions: '%v'", err))
 			return
 		}
-		a.Counters, err = db.GetActionCounters(a.ID)
-		if err != nil {
-			err = fmt.Errorf("Failed to retrieve action counters: '%v'", err)
-			return
+		defer rows.Close()
+
+		type rawAction struct {
+			a                          mig.Action
+			jDesc, jThreat, jOps, jSig []byte
 		}
-		a.Investigators, err = db.InvestigatorByActionID(a.ID)
+		raw := make(chan rawAction)
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(raw)
+			for rows.Next() {
+				var r rawAction
+				serr := rows.Scan(&r.a.ID, &r.a.Name, &r.a.Target,
+					&r.jDesc, &r.jThreat, &r.jOps, &r.a.ValidFrom, &r.a.ExpireAfter,
+					&r.a.StartTime, &r.a.FinishTime, &r.a.LastUpdateTime, &r.a.Status,
+					&r.jSig, &r.a.SyntaxVersion)
+				if serr != nil {
+					trySendErr(errc, fmt.Errorf("Error while retrieving action: '%v'", serr))
+					return
+				}
+				select {
+				case raw <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if rerr := rows.Err(); rerr != nil {
+				trySendErr(errc, fmt.Errorf("Failed to complete database query: '%v'", rerr))
+			}
+		}()
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range raw {
+					a := r.a
+					if uerr := json.Unmarshal(r.jThreat, &a.Threat); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action threat: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jDesc, &a.Description); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action description: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jOps, &a.Operations); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action operations: '%v'", uerr))
+						continue
+					}
+					if uerr := json.Unmarshal(r.jSig, &a.PGPSignatures); uerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to unmarshal action signatures: '%v'", uerr))
+						continue
+					}
+					var cerr error
+					a.Counters, cerr = db.GetActionCounters(a.ID)
+					if cerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to retrieve action counters: '%v'", cerr))
+						continue
+					}
+					a.Investigators, cerr = db.InvestigatorByActionID(a.ID)
+					if cerr != nil {
+						trySendErr(errc, fmt.Errorf("Failed to retrieve action investigators: '%v'", cerr))
+						continue
+					}
+					select {
+					case out <- a:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return out, errc
+}
+
+// SearchAgents returns an array of agents that match search parameters. When
+// p.Cursor is set, the result is paged by keyset instead of OFFSET, and
+// pagination.NextCursor carries the token for the following page (empty once
+// the result set is exhausted).
+func (db *DB) SearchAgents(p SearchParameters, auth Permissions) (agents []mig.Agent, pagination Pagination, err error) {
+	ctx := context.Background()
+	driver := defaultDriver
+	// a single worker keeps rows in query order (see SearchCommands)
+	agentChan, errChan := db.SearchAgentsStream(ctx, p, auth, 1)
+	for agent := range agentChan {
+		agents = append(agents, agent)
+	}
+	if err = <-errChan; err != nil {
+		return
+	}
+	useCursor := p.Cursor != ""
+	if useCursor && len(agents) == int(p.Limit) {
+		last := agents[len(agents)-1]
+		pagination.NextCursor, err = encodeCursor(agentCursor{HeartBeatTime: last.HeartBeatTS, ID: last.ID})
 		if err != nil {
-			err = fmt.Errorf("Failed to retrieve action investigators: '%v'", err)
 			return
 		}
-		actions = append(actions, a)
 	}
-	if err := rows.Err(); err != nil {
-		err = fmt.Errorf("Failed to complete database query: '%v'", err)
+	if total, esterr := estimateRowCount(db, driver, "agents"); esterr == nil {
+		pagination.Total = total
 	}
 	return
 }
 
-// SearchAgents returns an array of agents that match search parameters
-func (db *DB) SearchAgents(p SearchParameters) (agents []mig.Agent, err error) {
-	var (
-		rows                                      *sql.Rows
-		joinAction, joinInvestigator, joinCommand bool = false, false, false
-	)
-	ids, err := makeIDsFromParams(p)
-	if err != nil {
-		return
-	}
-	columns := `agents.id, agents.name, agents.queueloc, agents.mode,
+// SearchAgentsStream is the streaming counterpart to SearchAgents: it pushes
+// agents onto the returned channel as rows.Next() yields them instead of
+// buffering the whole result set. Agent rows carry no per-row follow-up
+// queries, so rows are scanned by one goroutine and workers only sets how
+// many goroutines hand them off to the channel (defaultStreamWorkers if
+// workers < 1). Cancelling ctx stops the query and closes both channels.
+func (db *DB) SearchAgentsStream(ctx context.Context, p SearchParameters, auth Permissions, workers int) (<-chan mig.Agent, <-chan error) {
+	out := make(chan mig.Agent)
+	errc := make(chan error, 1)
+	if workers < 1 {
+		workers = defaultStreamWorkers
+	}
+	driver := defaultDriver
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		var (
+			joinAction, joinInvestigator, joinCommand bool = false, false, false
+			cursor                                    agentCursor
+		)
+		useCursor := p.Cursor != ""
+		if useCursor {
+			if err := decodeCursor(p.Cursor, &cursor); err != nil {
+				trySendErr(errc, err)
+				return
+			}
+		}
+		ids, err := makeIDsFromParams(p)
+		if err != nil {
+			trySendErr(errc, err)
+			return
+		}
+		columns := `agents.id, agents.name, agents.queueloc, agents.mode,
 		agents.version, agents.pid, agents.starttime, agents.destructiontime,
 		agents.heartbeattime, agents.status`
-	join := ""
-	where := ""
-	vals := []interface{}{}
-	valctr := 0
-	if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
-		where += fmt.Sprintf(`agents.heartbeattime <= $%d `, valctr+1)
-		vals = append(vals, p.Before)
-		valctr += 1
-	}
-	if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.heartbeattime >= $%d `, valctr+1)
-		vals = append(vals, p.After)
-		valctr += 1
-	}
-	if p.AgentID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.id >= $%d AND agents.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minAgentID, ids.maxAgentID)
-		valctr += 2
-	}
-	if p.AgentName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.AgentName)
-		valctr += 1
-	}
-	if p.Status != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.status ILIKE $%d`, valctr+1)
-		vals = append(vals, p.Status)
-		valctr += 1
-	}
-	if p.ActionID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.id >= $%d AND actions.id <= $%d`, valctr+1, valctr+2)
-		vals = append(vals, ids.minActionID, ids.maxActionID)
-		valctr += 2
-		joinAction = true
-		joinCommand = true
-	}
-	if p.ActionName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.ActionName)
-		valctr += 1
-		joinAction = true
-		joinCommand = true
-	}
-	if p.ThreatFamily != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE $%d `, valctr+1)
-		vals = append(vals, p.ThreatFamily)
-		valctr += 1
-		joinAction = true
-		joinCommand = true
-	}
-	if p.InvestigatorID != "∞" {
-		if valctr > 0 {
-			where += " AND "
+		join := ""
+		where := ""
+		vals := []interface{}{}
+		valctr := 0
+		if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
+			where += fmt.Sprintf(`agents.heartbeattime <= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Before)
+			valctr += 1
+		}
+		if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.heartbeattime >= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.After)
+			valctr += 1
+		}
+		if p.AgentID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.id >= %s AND agents.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minAgentID, ids.maxAgentID)
+			valctr += 2
+		}
+		if p.AgentName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.AgentName)
+			valctr += 1
+		}
+		if p.Status != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.status ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Status)
+			valctr += 1
+		}
+		if p.ActionID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.id >= %s AND actions.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minActionID, ids.maxActionID)
+			valctr += 2
+			joinAction = true
+			joinCommand = true
+		}
+		if p.ActionName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ActionName)
+			valctr += 1
+			joinAction = true
+			joinCommand = true
+		}
+		if p.ThreatFamily != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ThreatFamily)
+			valctr += 1
+			joinAction = true
+			joinCommand = true
+		}
+		if p.InvestigatorID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.id >= %s AND investigators.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minInvID, ids.maxInvID)
+			valctr += 2
+			joinInvestigator = true
+			joinCommand = true
+			joinAction = true
+		}
+		if p.InvestigatorName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.InvestigatorName)
+			valctr += 1
+			joinInvestigator = true
+			joinCommand = true
+			joinAction = true
+		}
+		if p.CommandID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`commands.id >= %s AND commands.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minCommandID, ids.maxCommandID)
+			valctr += 2
+			joinCommand = true
+		}
+		if len(ids.actionIDRanges) > 0 || len(p.ActionNames) > 0 || len(p.ThreatFamilies) > 0 {
+			joinAction = true
+			joinCommand = true
+		}
+		if len(ids.invIDRanges) > 0 || len(p.InvestigatorNames) > 0 || p.HasInvestigator || p.NoInvestigator {
+			joinInvestigator = true
+			joinCommand = true
+			joinAction = true
+		}
+		if len(ids.commandIDRanges) > 0 {
+			joinCommand = true
+		}
+		if !auth.IsAdmin {
+			// joinInvestigator's ON-clause joins signatures to actions, so
+			// actions (and, since actions is only reached via commands,
+			// commands too) must come along whenever the investigator join
+			// is forced -- not just when AllowedTags also requires them.
+			joinInvestigator = true
+			joinCommand = true
+			joinAction = true
+		}
+		if joinCommand {
+			join += "INNER JOIN commands ON ( commands.agentid = agents.id) "
+		}
+		if joinAction {
+			join += " INNER JOIN actions ON ( commands.actionid = actions.id ) "
+		}
+		if joinInvestigator {
+			// NoInvestigator asks for agents that have never received a
+			// command signed by any investigator at all, which an INNER
+			// JOIN can never produce (it only emits matched rows) -- LEFT
+			// JOIN plus an investigators.id IS NULL predicate is required
+			// instead.
+			investigatorJoinType := "INNER"
+			if p.NoInvestigator {
+				investigatorJoinType = "LEFT"
+			}
+			join += fmt.Sprintf(` %s JOIN signatures ON ( actions.id = signatures.actionid )
+			%s JOIN investigators ON ( signatures.investigatorid = investigators.id ) `,
+				investigatorJoinType, investigatorJoinType)
+		}
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "agents.id", ids.agentIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "actions.id", ids.actionIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "investigators.id", ids.invIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "commands.id", ids.commandIDRanges)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "agents.name", p.AgentNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "agents.status", p.Statuses)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.name", p.ActionNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.threat#>>'{family}'", p.ThreatFamilies)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "investigators.name", p.InvestigatorNames)
+		where, vals, valctr = applyAuthScope(driver, where, vals, valctr, auth)
+		if useCursor {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`(agents.heartbeattime, agents.id) < (%s, %s)`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, cursor.HeartBeatTime, cursor.ID)
+			valctr += 2
+		}
+		if p.NoInvestigator {
+			// NoInvestigator binds no placeholder, so it's appended last,
+			// after every valctr-counted predicate above has already
+			// decided its own " AND " prefix.
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += "investigators.id IS NULL"
+		}
+		var query string
+		if useCursor {
+			query = fmt.Sprintf(`SELECT %s FROM agents %s WHERE %s GROUP BY agents.id
+			ORDER BY agents.heartbeattime DESC LIMIT %s;`,
+				columns, join, where, driver.Placeholder(valctr+1))
+			vals = append(vals, uint64(p.Limit))
+		} else {
+			query = fmt.Sprintf(`SELECT %s FROM agents %s WHERE %s GROUP BY agents.id
+			ORDER BY agents.heartbeattime DESC LIMIT %s OFFSET %s;`,
+				columns, join, where, driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, uint64(p.Limit), uint64(p.Offset))
 		}
-		where += fmt.Sprintf(`investigators.id >= $%d AND investigators.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minInvID, ids.maxInvID)
-		valctr += 2
-		joinInvestigator = true
-		joinCommand = true
-		joinAction = true
-	}
-	if p.InvestigatorName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.InvestigatorName)
-		valctr += 1
-		joinInvestigator = true
-		joinCommand = true
-		joinAction = true
-	}
-	if p.CommandID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`commands.id >= $%d AND commands.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minCommandID, ids.maxCommandID)
-		valctr += 2
-		joinCommand = true
-	}
-	if joinCommand {
-		join += "INNER JOIN commands ON ( commands.agentid = agents.id) "
-	}
-	if joinAction {
-		join += " INNER JOIN actions ON ( commands.actionid = actions.id ) "
-	}
-	if joinInvestigator {
-		join += ` INNER JOIN signatures ON ( actions.id = signatures.actionid )
-			INNER JOIN investigators ON ( signatures.investigatorid = investigators.id ) `
-	}
-	query := fmt.Sprintf(`SELECT %s FROM agents %s WHERE %s GROUP BY agents.id
-		ORDER BY agents.heartbeattime DESC LIMIT $%d OFFSET $%d;`,
-		columns, join, where, valctr+1, valctr+2)
-	vals = append(vals, uint64(p.Limit), uint64(p.Offset))
 
-	stmt, err := db.c.Prepare(query)
-	if stmt != nil {
-		defer stmt.Close()
-	}
-	if err != nil {
-		err = fmt.Errorf("Error while preparing search statement: '%v' in '%s'", err, query)
-		return
-	}
-	rows, err = stmt.Query(vals...)
-	if rows != nil {
+		begin := time.Now()
+		rows, err := db.c.QueryContext(ctx, query, vals...)
+		defaultLogger.Trace(ctx, begin, query, vals, -1, err)
+		if err != nil {
+			trySendErr(errc, fmt.Errorf("Error while finding agents: '%v'", err))
+			return
+		}
 		defer rows.Close()
+
+		raw := make(chan mig.Agent)
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(raw)
+			for rows.Next() {
+				var agent mig.Agent
+				serr := rows.Scan(&agent.ID, &agent.Name, &agent.QueueLoc, &agent.Mode, &agent.Version,
+					&agent.PID, &agent.StartTime, &agent.DestructionTime, &agent.HeartBeatTS,
+					&agent.Status)
+				if serr != nil {
+					trySendErr(errc, fmt.Errorf("Failed to retrieve agent data: '%v'", serr))
+					return
+				}
+				select {
+				case raw <- agent:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if rerr := rows.Err(); rerr != nil {
+				trySendErr(errc, fmt.Errorf("Failed to complete database query: '%v'", rerr))
+			}
+		}()
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for agent := range raw {
+					select {
+					case out <- agent:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return out, errc
+}
+
+// SearchInvestigators returns an array of investigators that match search
+// parameters. When p.Cursor is set, the result is paged by keyset instead of
+// OFFSET, and pagination.NextCursor carries the token for the following page
+// (empty once the result set is exhausted). p.AfterID/p.AfterCreatedAt select
+// the same keyset paging in the opposite (ascending) direction for callers
+// that track the keyset themselves instead of round-tripping Cursor; they're
+// ignored when p.Cursor is set.
+func (db *DB) SearchInvestigators(p SearchParameters, auth Permissions) (investigators []mig.Investigator, pagination Pagination, err error) {
+	ctx := context.Background()
+	driver := defaultDriver
+	// a single worker keeps rows in query order (see SearchCommands)
+	invChan, errChan := db.SearchInvestigatorsStream(ctx, p, auth, 1)
+	for inv := range invChan {
+		investigators = append(investigators, inv)
 	}
-	if err != nil {
-		err = fmt.Errorf("Error while finding agents: '%v'", err)
+	if err = <-errChan; err != nil {
 		return
 	}
-	for rows.Next() {
-		var agent mig.Agent
-		err = rows.Scan(&agent.ID, &agent.Name, &agent.QueueLoc, &agent.Mode, &agent.Version,
-			&agent.PID, &agent.StartTime, &agent.DestructionTime, &agent.HeartBeatTS,
-			&agent.Status)
+	useCursor := p.Cursor != ""
+	// See the equivalent comment in SearchActions: NextCursor only ever
+	// encodes a descending (Cursor-style) token, so it's left empty on the
+	// AfterID/AfterCreatedAt (ascending) path rather than handing back a
+	// token that would flip direction if round-tripped as p.Cursor.
+	if useCursor && len(investigators) == int(p.Limit) {
+		last := investigators[len(investigators)-1]
+		pagination.NextCursor, err = encodeCursor(investigatorCursor{LastModified: last.LastModified, ID: last.ID})
 		if err != nil {
-			err = fmt.Errorf("Failed to retrieve agent data: '%v'", err)
 			return
 		}
-		agents = append(agents, agent)
 	}
-	if err := rows.Err(); err != nil {
-		err = fmt.Errorf("Failed to complete database query: '%v'", err)
+	if total, esterr := estimateRowCount(db, driver, "investigators"); esterr == nil {
+		pagination.Total = total
 	}
-
 	return
 }
 
-// SearchInvestigators returns an array of investigators that match search parameters
-func (db *DB) SearchInvestigators(p SearchParameters) (investigators []mig.Investigator, err error) {
-	var (
-		rows                               *sql.Rows
-		joinAction, joinAgent, joinCommand bool = false, false, false
-	)
-	ids, err := makeIDsFromParams(p)
-	if err != nil {
-		return
-	}
-	columns := `investigators.id, investigators.name, investigators.pgpfingerprint,
-		investigators.status, investigators.createdat, investigators.lastmodified`
-	join := ""
-	where := ""
-	vals := []interface{}{}
-	valctr := 0
-	if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
-		where += fmt.Sprintf(`investigators.lastmodified <= $%d `, valctr+1)
-		vals = append(vals, p.Before)
-		valctr += 1
-	}
-	if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.lastmodified >= $%d `, valctr+1)
-		vals = append(vals, p.After)
-		valctr += 1
-	}
-	if p.InvestigatorID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.id >= $%d AND investigators.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minInvID, ids.maxInvID)
-		valctr += 2
-	}
-	if p.InvestigatorName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.InvestigatorName)
-		valctr += 1
-	}
-	if p.Status != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`investigators.status ILIKE $%d`, valctr+1)
-		vals = append(vals, p.Status)
-		valctr += 1
-	}
-	if p.ActionID != "∞" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.id >= $%d AND actions.id <= $%d`, valctr+1, valctr+2)
-		vals = append(vals, ids.minActionID, ids.maxActionID)
-		valctr += 2
-		joinAction = true
-	}
-	if p.ActionName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`actions.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.ActionName)
-		valctr += 1
-		joinAction = true
-	}
-	if p.ThreatFamily != "%" {
-		if valctr > 0 {
-			where += " AND "
+// investigatorRow mirrors mig.Investigator with db tags, so its SELECT list
+// and scan targets are generated from the same source via Columns/ScanOne
+// instead of drifting apart like the hand-rolled rows.Scan calls elsewhere
+// in this file.
+type investigatorRow struct {
+	ID             float64   `db:"investigators.id"`
+	Name           string    `db:"investigators.name"`
+	PGPFingerprint string    `db:"investigators.pgpfingerprint"`
+	Status         string    `db:"investigators.status"`
+	CreatedAt      time.Time `db:"investigators.createdat"`
+	LastModified   time.Time `db:"investigators.lastmodified"`
+}
+
+// SearchInvestigatorsStream is the streaming counterpart to
+// SearchInvestigators: it pushes investigators onto the returned channel as
+// rows.Next() yields them instead of buffering the whole result set.
+// Investigator rows carry no per-row follow-up queries, so rows are scanned
+// by one goroutine and workers only sets how many goroutines hand them off
+// to the channel (defaultStreamWorkers if workers < 1). Cancelling ctx stops
+// the query and closes both channels.
+func (db *DB) SearchInvestigatorsStream(ctx context.Context, p SearchParameters, auth Permissions, workers int) (<-chan mig.Investigator, <-chan error) {
+	out := make(chan mig.Investigator)
+	errc := make(chan error, 1)
+	if workers < 1 {
+		workers = defaultStreamWorkers
+	}
+	driver := defaultDriver
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		var (
+			joinAction, joinAgent, joinCommand bool = false, false, false
+			cursor                             investigatorCursor
+		)
+		useCursor := p.Cursor != ""
+		if useCursor {
+			if err := decodeCursor(p.Cursor, &cursor); err != nil {
+				trySendErr(errc, err)
+				return
+			}
+		}
+		useAfter := !useCursor && (p.AfterID != 0 || !p.AfterCreatedAt.IsZero())
+		ids, err := makeIDsFromParams(p)
+		if err != nil {
+			trySendErr(errc, err)
+			return
 		}
-		where += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE $%d `, valctr+1)
-		vals = append(vals, p.ThreatFamily)
-		valctr += 1
-		joinAction = true
-	}
-	if p.CommandID != "∞" {
-		if valctr > 0 {
-			where += " AND "
+		columns := strings.Join(Columns[investigatorRow](), ", ")
+		join := ""
+		where := ""
+		vals := []interface{}{}
+		valctr := 0
+		if p.Before.Before(time.Now().Add(defaultSearchPeriod - time.Hour)) {
+			where += fmt.Sprintf(`investigators.lastmodified <= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Before)
+			valctr += 1
+		}
+		if p.After.After(time.Now().Add(-(defaultSearchPeriod - time.Hour))) {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.lastmodified >= %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.After)
+			valctr += 1
+		}
+		if p.InvestigatorID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.id >= %s AND investigators.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minInvID, ids.maxInvID)
+			valctr += 2
+		}
+		if p.InvestigatorName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.InvestigatorName)
+			valctr += 1
+		}
+		if p.Status != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`investigators.status ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.Status)
+			valctr += 1
+		}
+		if p.ActionID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.id >= %s AND actions.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minActionID, ids.maxActionID)
+			valctr += 2
+			joinAction = true
+		}
+		if p.ActionName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ActionName)
+			valctr += 1
+			joinAction = true
+		}
+		if p.ThreatFamily != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`actions.threat#>>'{family}' ILIKE %s `,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.ThreatFamily)
+			valctr += 1
+			joinAction = true
+		}
+		if p.CommandID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`commands.id >= %s AND commands.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minCommandID, ids.maxCommandID)
+			valctr += 2
+			joinCommand = true
+			joinAction = true
+		}
+		if p.AgentID != "∞" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.id >= %s AND agents.id <= %s`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, ids.minAgentID, ids.maxAgentID)
+			valctr += 2
+			joinCommand = true
+			joinAction = true
+			joinAgent = true
+		}
+		if p.AgentName != "%" {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`agents.name ILIKE %s`,
+				driver.Placeholder(valctr+1))
+			vals = append(vals, p.AgentName)
+			valctr += 1
+			joinCommand = true
+			joinAction = true
+			joinAgent = true
+		}
+		if len(ids.actionIDRanges) > 0 || len(p.ActionNames) > 0 || len(p.ThreatFamilies) > 0 {
+			joinAction = true
+		}
+		if len(ids.commandIDRanges) > 0 {
+			joinCommand = true
+			joinAction = true
+		}
+		if len(ids.agentIDRanges) > 0 || len(p.AgentNames) > 0 {
+			joinCommand = true
+			joinAction = true
+			joinAgent = true
+		}
+		if !auth.IsAdmin && len(auth.AllowedTags) > 0 {
+			joinCommand = true
+			joinAction = true
+			joinAgent = true
+		}
+		if joinAction {
+			join += ` INNER JOIN signatures ON ( signatures.investigatorid = investigators.id )
+			INNER JOIN actions ON ( actions.id = signatures.actionid ) `
 		}
-		where += fmt.Sprintf(`commands.id >= $%d AND commands.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minCommandID, ids.maxCommandID)
-		valctr += 2
-		joinCommand = true
-		joinAction = true
-	}
-	if p.AgentID != "∞" {
-		if valctr > 0 {
-			where += " AND "
+		if joinCommand {
+			join += "INNER JOIN commands ON ( commands.actionid = actions.id) "
+		}
+		if joinAgent {
+			join += " INNER JOIN agents ON ( commands.agentid = agents.id ) "
+		}
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "investigators.id", ids.invIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "actions.id", ids.actionIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "commands.id", ids.commandIDRanges)
+		appendIDRangesWhere(driver, &where, &vals, &valctr, "agents.id", ids.agentIDRanges)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "investigators.name", p.InvestigatorNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "investigators.status", p.Statuses)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.name", p.ActionNames)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "actions.threat#>>'{family}'", p.ThreatFamilies)
+		appendILikeAnyWhere(driver, &where, &vals, &valctr, "agents.name", p.AgentNames)
+		where, vals, valctr = applyAuthScope(driver, where, vals, valctr, auth)
+		if useCursor {
+			if valctr > 0 {
+				where += " AND "
+			}
+			where += fmt.Sprintf(`(investigators.lastmodified, investigators.id) < (%s, %s)`,
+				driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, cursor.LastModified, cursor.ID)
+			valctr += 2
+		} else if useAfter {
+			if valctr > 0 {
+				where += " AND "
+			}
+			if !p.AfterCreatedAt.IsZero() {
+				where += fmt.Sprintf(`(investigators.lastmodified, investigators.id) > (%s, %s)`,
+					driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+				vals = append(vals, p.AfterCreatedAt, p.AfterID)
+				valctr += 2
+			} else {
+				where += fmt.Sprintf(`investigators.id > %s`, driver.Placeholder(valctr+1))
+				vals = append(vals, p.AfterID)
+				valctr++
+			}
+		}
+		var query string
+		switch {
+		case useCursor:
+			query = fmt.Sprintf(`SELECT %s FROM investigators %s WHERE %s GROUP BY investigators.id
+			ORDER BY investigators.lastmodified DESC, investigators.id DESC LIMIT %s;`,
+				columns, join, where, driver.Placeholder(valctr+1))
+			vals = append(vals, uint64(p.Limit))
+		case useAfter:
+			query = fmt.Sprintf(`SELECT %s FROM investigators %s WHERE %s GROUP BY investigators.id
+			ORDER BY investigators.lastmodified ASC, investigators.id ASC LIMIT %s;`,
+				columns, join, where, driver.Placeholder(valctr+1))
+			vals = append(vals, uint64(p.Limit))
+		default:
+			query = fmt.Sprintf(`SELECT %s FROM investigators %s WHERE %s GROUP BY investigators.id
+			ORDER BY investigators.id ASC LIMIT %s OFFSET %s;`,
+				columns, join, where, driver.Placeholder(valctr+1), driver.Placeholder(valctr+2))
+			vals = append(vals, uint64(p.Limit), uint64(p.Offset))
 		}
-		where += fmt.Sprintf(`agents.id >= $%d AND agents.id <= $%d`,
-			valctr+1, valctr+2)
-		vals = append(vals, ids.minAgentID, ids.maxAgentID)
-		valctr += 2
-		joinCommand = true
-		joinAction = true
-		joinAgent = true
-	}
-	if p.AgentName != "%" {
-		if valctr > 0 {
-			where += " AND "
-		}
-		where += fmt.Sprintf(`agents.name ILIKE $%d`, valctr+1)
-		vals = append(vals, p.AgentName)
-		valctr += 1
-		joinCommand = true
-		joinAction = true
-		joinAgent = true
-	}
-	if joinAction {
-		join += ` INNER JOIN signatures ON ( signatures.investigatorid = investigators.id ) 
-			INNER JOIN actions ON ( actions.id = signatures.actionid ) `
-	}
-	if joinCommand {
-		join += "INNER JOIN commands ON ( commands.actionid = actions.id) "
-	}
-	if joinAgent {
-		join += " INNER JOIN agents ON ( commands.agentid = agents.id ) "
-	}
-	query := fmt.Sprintf(`SELECT %s FROM investigators %s WHERE %s GROUP BY investigators.id
-		ORDER BY investigators.id ASC LIMIT $%d OFFSET $%d;`,
-		columns, join, where, valctr+1, valctr+2)
-	vals = append(vals, uint64(p.Limit), uint64(p.Offset))
 
-	stmt, err := db.c.Prepare(query)
-	if stmt != nil {
-		defer stmt.Close()
-	}
-	if err != nil {
-		err = fmt.Errorf("Error while preparing search statement: '%v' in '%s'", err, query)
-		return
-	}
-	rows, err = stmt.Query(vals...)
-	if rows != nil {
-		defer rows.Close()
-	}
-	if err != nil {
-		err = fmt.Errorf("Error while finding investigators: '%v'", err)
-		return
-	}
-	for rows.Next() {
-		var inv mig.Investigator
-		err = rows.Scan(&inv.ID, &inv.Name, &inv.PGPFingerprint, &inv.Status, &inv.CreatedAt, &inv.LastModified)
+		begin := time.Now()
+		rows, err := db.c.QueryContext(ctx, query, vals...)
+		defaultLogger.Trace(ctx, begin, query, vals, -1, err)
 		if err != nil {
-			err = fmt.Errorf("Failed to retrieve investigator data: '%v'", err)
+			trySendErr(errc, fmt.Errorf("Error while finding investigators: '%v'", err))
 			return
 		}
-		investigators = append(investigators, inv)
-	}
-	if err := rows.Err(); err != nil {
-		err = fmt.Errorf("Failed to complete database query: '%v'", err)
-	}
-	return
-}
\ No newline at end of file
+		defer rows.Close()
+
+		raw := make(chan mig.Investigator)
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(raw)
+			for rows.Next() {
+				var row investigatorRow
+				if serr := ScanOne(rows, &row); serr != nil {
+					trySendErr(errc, fmt.Errorf("Failed to retrieve investigator data: '%v'", serr))
+					return
+				}
+				inv := mig.Investigator{
+					ID:             row.ID,
+					Name:           row.Name,
+					PGPFingerprint: row.PGPFingerprint,
+					Status:         row.Status,
+					CreatedAt:      row.CreatedAt,
+					LastModified:   row.LastModified,
+				}
+				select {
+				case raw <- inv:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if rerr := rows.Err(); rerr != nil {
+				trySendErr(errc, fmt.Errorf("Failed to complete database query: '%v'", rerr))
+			}
+		}()
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for inv := range raw {
+					select {
+					case out <- inv:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return out, errc
+}
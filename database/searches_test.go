@@ -0,0 +1,147 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeCursor(t *testing.T) {
+	want := actionCursor{ValidFrom: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), ID: 42}
+	token, err := encodeCursor(want)
+	if err != nil {
+		t.Fatalf("encodeCursor: unexpected error: %v", err)
+	}
+	var got actionCursor
+	if err := decodeCursor(token, &got); err != nil {
+		t.Fatalf("decodeCursor: unexpected error: %v", err)
+	}
+	if !got.ValidFrom.Equal(want.ValidFrom) || got.ID != want.ID {
+		t.Errorf("decodeCursor: got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	var got actionCursor
+	if err := decodeCursor("not-valid-base64!!", &got); err == nil {
+		t.Error("decodeCursor: expected an error for invalid base64, got none")
+	}
+	token, err := encodeCursor([]int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("encodeCursor: unexpected error: %v", err)
+	}
+	if err := decodeCursor(token, &got); err == nil {
+		t.Error("decodeCursor: expected an error unmarshaling a JSON array into actionCursor, got none")
+	}
+}
+
+func TestIdRangesFromList(t *testing.T) {
+	ranges, err := idRangesFromList([]string{"1", "2.0", "42"})
+	if err != nil {
+		t.Fatalf("idRangesFromList: unexpected error: %v", err)
+	}
+	want := []IDRange{{Min: 1, Max: 1}, {Min: 2, Max: 2}, {Min: 42, Max: 42}}
+	if len(ranges) != len(want) {
+		t.Fatalf("idRangesFromList: got %d ranges, want %d", len(ranges), len(want))
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("idRangesFromList: range %d = %+v, want %+v", i, ranges[i], want[i])
+		}
+	}
+	if _, err := idRangesFromList([]string{"not-a-number"}); err == nil {
+		t.Error("idRangesFromList: expected an error for a non-numeric value, got none")
+	}
+}
+
+func TestAppendIDRangesWhere(t *testing.T) {
+	driver := postgresDriver{}
+	var where string
+	var vals []interface{}
+	var valctr int
+	appendIDRangesWhere(driver, &where, &vals, &valctr, "actions.id", []IDRange{{Min: 1, Max: 2}, {Min: 5, Max: 5}})
+	wantWhere := `((actions.id >= $1 AND actions.id <= $2) OR (actions.id >= $3 AND actions.id <= $4))`
+	if where != wantWhere {
+		t.Errorf("appendIDRangesWhere: where = %q, want %q", where, wantWhere)
+	}
+	if valctr != 4 {
+		t.Errorf("appendIDRangesWhere: valctr = %d, want 4", valctr)
+	}
+	if len(vals) != 4 {
+		t.Errorf("appendIDRangesWhere: len(vals) = %d, want 4", len(vals))
+	}
+
+	// A second call must prepend " AND " since where is already non-empty.
+	appendIDRangesWhere(driver, &where, &vals, &valctr, "agents.id", []IDRange{{Min: 9, Max: 9}})
+	if where[len(wantWhere):len(wantWhere)+5] != " AND " {
+		t.Errorf("appendIDRangesWhere: second call did not prepend ' AND ', got %q", where)
+	}
+
+	// An empty range list must be a no-op.
+	before := where
+	appendIDRangesWhere(driver, &where, &vals, &valctr, "commands.id", nil)
+	if where != before {
+		t.Errorf("appendIDRangesWhere: empty ranges modified where: got %q, want %q", where, before)
+	}
+}
+
+func TestAppendILikeAnyWhere(t *testing.T) {
+	driver := postgresDriver{}
+	var where string
+	var vals []interface{}
+	var valctr int
+	appendILikeAnyWhere(driver, &where, &vals, &valctr, "agents.name", []string{"foo%", "bar%"})
+	wantWhere := `agents.name ILIKE ANY($1)`
+	if where != wantWhere {
+		t.Errorf("appendILikeAnyWhere: where = %q, want %q", where, wantWhere)
+	}
+	if valctr != 1 {
+		t.Errorf("appendILikeAnyWhere: valctr = %d, want 1", valctr)
+	}
+	if len(vals) != 1 {
+		t.Errorf("appendILikeAnyWhere: len(vals) = %d, want 1", len(vals))
+	}
+}
+
+func TestApplyAuthScopeAdmin(t *testing.T) {
+	driver := postgresDriver{}
+	where, vals, valctr := applyAuthScope(driver, "actions.id = $1", []interface{}{42}, 1, Permissions{IsAdmin: true})
+	if where != "actions.id = $1" || valctr != 1 || len(vals) != 1 {
+		t.Errorf("applyAuthScope: admin call was modified: where=%q vals=%v valctr=%d", where, vals, valctr)
+	}
+}
+
+func TestApplyAuthScopeNonAdminNoTags(t *testing.T) {
+	driver := postgresDriver{}
+	where, vals, valctr := applyAuthScope(driver, "", nil, 0, Permissions{InvestigatorID: 7})
+	wantWhere := `investigators.id = $1`
+	if where != wantWhere {
+		t.Errorf("applyAuthScope: where = %q, want %q", where, wantWhere)
+	}
+	if valctr != 1 || len(vals) != 1 || vals[0].(float64) != 7 {
+		t.Errorf("applyAuthScope: vals/valctr = %v/%d, want [7]/1", vals, valctr)
+	}
+}
+
+// A tag-scoped investigator must be widened to "their own rows OR the
+// tagged agents", not narrowed to the intersection of both -- AllowedTags
+// grants additional visibility on top of investigators.id, it doesn't
+// restrict it.
+func TestApplyAuthScopeTagScopedIsOrNotAnd(t *testing.T) {
+	driver := postgresDriver{}
+	auth := Permissions{
+		InvestigatorID: 7,
+		AllowedTags:    []TagFilter{{Key: "env", Value: "prod"}},
+	}
+	where, vals, valctr := applyAuthScope(driver, "", nil, 0, auth)
+	wantWhere := `(investigators.id = $1 OR (agents.tags @> $2))`
+	if where != wantWhere {
+		t.Errorf("applyAuthScope: where = %q, want %q", where, wantWhere)
+	}
+	if valctr != 2 || len(vals) != 2 {
+		t.Errorf("applyAuthScope: vals/valctr = %v/%d, want 2 values/2", vals, valctr)
+	}
+}